@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// CollectionPartStatus est un film d'une collection avec son statut (seen, watchlist ou
+// unseen, comme pour /api/people/{id}/completion).
+type CollectionPartStatus struct {
+	TMDBID        int    `json:"tmdb_id" db:"tmdb_id"`
+	Position      int    `json:"position" db:"position"`
+	Title         string `json:"title" db:"title"`
+	ReleaseDate   string `json:"release_date" db:"release_date"`
+	PosterPath    string `json:"poster_path" db:"poster_path"`
+	Status        string `json:"status" db:"status"`
+	LetterboxdURI string `json:"letterboxd_uri,omitempty" db:"letterboxd_uri"`
+}
+
+// CollectionProgress indique combien de films d'une collection ont été vus.
+type CollectionProgress struct {
+	ID          int                    `json:"id" db:"id"`
+	Name        string                 `json:"name" db:"name"`
+	PosterPath  string                 `json:"poster_path" db:"poster_path"`
+	Total       int                    `json:"total"`
+	Seen        int                    `json:"seen"`
+	InWatchlist int                    `json:"in_watchlist"`
+	Unseen      int                    `json:"unseen"`
+	Ratio       float64                `json:"completion"`
+	Parts       []CollectionPartStatus `json:"parts"`
+}
+
+// collectionsHandler renvoie chaque collection avec ses films dans l'ordre de sortie, des
+// plus vues aux moins vues. incomplete=true ne garde que les collections commencées mais
+// pas terminées.
+func collectionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	var collections []CollectionProgress
+	err := db.Select(&collections, `SELECT id, name, IFNULL(poster_path, '') AS poster_path FROM collections`)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération des collections"}`, http.StatusInternalServerError)
+		return
+	}
+	var parts []struct {
+		CollectionID int `db:"collection_id"`
+		CollectionPartStatus
+	}
+	err = db.Select(&parts, `
+        SELECT p.collection_id, p.tmdb_id, p.position, IFNULL(p.title, '') AS title,
+            IFNULL(p.release_date, '') AS release_date, IFNULL(p.poster_path, '') AS poster_path,
+            `+watchStatusSQL("p.tmdb_id")+` AS status,
+            `+letterboxdURISQL("p.tmdb_id")+` AS letterboxd_uri
+        FROM collection_parts p
+        ORDER BY p.collection_id, p.position
+    `)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération des collections"}`, http.StatusInternalServerError)
+		return
+	}
+
+	byID := make(map[int]*CollectionProgress, len(collections))
+	for i := range collections {
+		collections[i].Parts = []CollectionPartStatus{}
+		byID[collections[i].ID] = &collections[i]
+	}
+	for _, p := range parts {
+		c, ok := byID[p.CollectionID]
+		if !ok {
+			continue
+		}
+		c.Parts = append(c.Parts, p.CollectionPartStatus)
+		switch p.Status {
+		case "seen":
+			c.Seen++
+		case "watchlist":
+			c.InWatchlist++
+		default:
+			c.Unseen++
+		}
+	}
+
+	incomplete := r.URL.Query().Get("incomplete") == "true"
+	result := []CollectionProgress{}
+	for _, c := range collections {
+		c.Total = len(c.Parts)
+		if c.Total > 0 {
+			c.Ratio = float64(c.Seen) / float64(c.Total)
+		}
+		if incomplete && (c.Seen == 0 || c.Seen == c.Total) {
+			continue
+		}
+		result = append(result, c)
+	}
+	slices.SortStableFunc(result, func(a, b CollectionProgress) int {
+		if a.Seen != b.Seen {
+			return b.Seen - a.Seen
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	json.NewEncoder(w).Encode(result)
+}
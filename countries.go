@@ -0,0 +1,32 @@
+package main
+
+// CountryStat compte les films vus produits par un pays, identifié par son code ISO 3166-1.
+// Une coproduction compte pour chacun de ses pays.
+type CountryStat struct {
+	ISO3166_1     string `json:"iso_3166_1" db:"iso_3166_1"`
+	Country       string `json:"country" db:"country"`
+	Count         int    `json:"count" db:"count"`                 // Films produits ou coproduits
+	PrimaryCount  int    `json:"primary_count" db:"primary_count"` // Films dont c'est le pays principal
+	CoProductions int    `json:"co_productions" db:"co_productions"`
+}
+
+// countryStats compte, pour chaque pays, les films vus produits ou coproduits, ceux dont il
+// est le pays principal et les coproductions, du plus au moins représenté. Les films de la
+// seule watchlist ne comptent pas.
+func countryStats() ([]CountryStat, error) {
+	stats := []CountryStat{}
+	err := db.Select(&stats, `
+        SELECT c.iso_3166_1, c.name AS country,
+            COUNT(*) AS count,
+            SUM(mc.is_primary) AS primary_count,
+            SUM(n.countries > 1) AS co_productions
+        FROM (SELECT DISTINCT letterboxd_uri FROM watched) w
+        JOIN movie_countries mc ON mc.letterboxd_uri = w.letterboxd_uri
+        JOIN countries c ON c.iso_3166_1 = mc.iso_3166_1
+        JOIN (SELECT letterboxd_uri, COUNT(*) AS countries FROM movie_countries GROUP BY letterboxd_uri) n
+            ON n.letterboxd_uri = mc.letterboxd_uri
+        GROUP BY c.iso_3166_1
+        ORDER BY count DESC, country
+    `)
+	return stats, err
+}
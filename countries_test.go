@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+)
+
+func TestCountryStats(t *testing.T) {
+	discardLog(t)
+	testDB := openTestDB(t)
+	useTestDB(t, testDB)
+	importTestExport(t, testDB, testExport(t, map[string]string{
+		"watched.csv":   "Date,Name,Year,Letterboxd URI\n2024-01-02,Alien,1979,https://boxd.it/2b0k\n2024-01-03,Brazil,1985,https://boxd.it/29Nu\n",
+		"watchlist.csv": "Date,Name,Year,Letterboxd URI\n2024-01-04,Stalker,1979,https://boxd.it/2aEe\n",
+	}))
+	gb := store.ProductionCountry{ISO3166_1: "GB", Name: "United Kingdom"}
+	us := store.ProductionCountry{ISO3166_1: "US", Name: "United States of America"}
+	su := store.ProductionCountry{ISO3166_1: "SU", Name: "Soviet Union"}
+	for _, m := range []store.Movie{
+		{LetterboxdURI: "https://boxd.it/2b0k", TMDBID: 348, ProductionCountries: []store.ProductionCountry{us, gb}},
+		{LetterboxdURI: "https://boxd.it/29Nu", TMDBID: 68, ProductionCountries: []store.ProductionCountry{gb}},
+		{LetterboxdURI: "https://boxd.it/2aEe", TMDBID: 1398, ProductionCountries: []store.ProductionCountry{su}},
+	} {
+		if _, err := store.SaveMovie(testDB, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := countryStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]CountryStat)
+	for _, s := range stats {
+		got[s.ISO3166_1] = s
+	}
+	tests := []struct {
+		iso                           string
+		count, primary, coProductions int
+	}{
+		{"GB", 2, 1, 1},
+		{"US", 1, 1, 1},
+		{"SU", 0, 0, 0}, // Seulement dans la watchlist
+	}
+	for _, tt := range tests {
+		s := got[tt.iso]
+		if s.Count != tt.count || s.PrimaryCount != tt.primary || s.CoProductions != tt.coProductions {
+			t.Errorf("%s: count %d, primary %d, co-productions %d; want %d, %d, %d",
+				tt.iso, s.Count, s.PrimaryCount, s.CoProductions, tt.count, tt.primary, tt.coProductions)
+		}
+	}
+}
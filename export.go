@@ -0,0 +1,256 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// letterboxdExport donne accès aux fichiers d'un export Letterboxd, qu'il s'agisse de
+// l'archive ZIP d'origine (lue sans extraction) ou d'un dossier décompressé.
+type letterboxdExport struct {
+	path   string            // Chemin de l'archive ou du dossier
+	fsys   fs.FS             // Contenu de l'export
+	files  map[string]string // Nom relatif à la racine de l'export -> chemin dans fsys
+	closer io.Closer
+}
+
+// export est l'export actuellement servi par dataHandler. Il est remplacé, sous exportMu,
+// lorsqu'un export envoyé sur /api/imports a été importé.
+var (
+	export   *letterboxdExport
+	exportMu sync.RWMutex
+)
+
+// setExport remplace l'export servi, ferme le précédent et renvoie son chemin ("" s'il n'y
+// en avait pas).
+func setExport(exp *letterboxdExport) string {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	previous := ""
+	if export != nil {
+		previous = export.path
+		export.Close()
+	}
+	export = exp
+	return previous
+}
+
+// closeExport ferme l'export servi à l'arrêt du serveur.
+func closeExport() {
+	setExport(nil)
+}
+
+// ignoredExportDirs liste les sous-dossiers de l'export qui ne décrivent pas l'activité
+// courante. deleted/ liste les entrées de journal, critiques et listes supprimées sur
+// Letterboxd, et orphaned/ les entrées dont le film a disparu : l'import n'en a pas besoin,
+// car les fichiers principaux décrivent tout le compte à la date de l'export et chaque ligne
+// qui en est absente est supprimée à l'import (voir csvSnapshots et importLists), qu'elle
+// figure ou non dans deleted/.
+var ignoredExportDirs = map[string]bool{
+	"deleted":  true,
+	"orphaned": true,
+}
+
+// findExportPath choisit l'export à importer : le chemin donné en argument, sinon l'archive
+// letterboxd-*.zip la plus récente du dossier courant, sinon l'ancien dossier "stats". Un
+// dossier stats resté à côté d'une archive est ignoré : il date d'avant l'import des
+// archives telles quelles.
+func findExportPath(arg string) (string, error) {
+	if arg != "" {
+		return arg, nil
+	}
+	archives, err := filepath.Glob("letterboxd-*.zip")
+	if err != nil {
+		return "", err
+	}
+	if len(archives) > 0 {
+		// Les archives sont nommées letterboxd-<membre>-<date>, l'ordre alphabétique suit la date
+		sort.Strings(archives)
+		latest := archives[len(archives)-1]
+		if _, err := os.Stat("stats"); err == nil {
+			log.Printf("Import de l'archive %s, la plus récente ; le dossier stats est ignoré", latest)
+		}
+		return latest, nil
+	}
+	if info, err := os.Stat("stats"); err == nil && info.IsDir() {
+		log.Println("Aucune archive letterboxd-*.zip, import du dossier stats")
+		return "stats", nil
+	}
+	return "", fmt.Errorf("aucun export trouvé : ni archive letterboxd-*.zip, ni dossier stats")
+}
+
+// openExport ouvre un export Letterboxd, archive ZIP ou dossier.
+func openExport(p string) (*letterboxdExport, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return newExport(p, os.DirFS(p), nil)
+	}
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, fmt.Errorf("archive %s illisible: %w", p, err)
+	}
+	exp, err := newExport(p, zr, zr)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	return exp, nil
+}
+
+// newExport repère les fichiers de l'export dans fsys. La racine de l'export est le dossier
+// le moins profond contenant watched.csv, ce qui couvre les archives dont le contenu est
+// placé dans un dossier parent.
+func newExport(p string, fsys fs.FS, closer io.Closer) (*letterboxdExport, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if ignoredExportDirs[d.Name()] || strings.HasPrefix(d.Name(), "__MACOSX") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if ext := path.Ext(name); ext == ".csv" || ext == ".json" {
+			paths = append(paths, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	root := ""
+	for _, name := range paths {
+		if path.Base(name) != "watched.csv" {
+			continue
+		}
+		if dir := path.Dir(name); root == "" || len(dir) < len(root) {
+			root = dir
+		}
+	}
+	if root == "" {
+		return nil, fmt.Errorf("%s ne contient pas de watched.csv, est-ce bien un export Letterboxd ?", p)
+	}
+
+	files := make(map[string]string)
+	for _, name := range paths {
+		rel := name
+		if root != "." {
+			if !strings.HasPrefix(name, root+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, root+"/")
+		}
+		files[rel] = name
+	}
+	return &letterboxdExport{path: p, fsys: fsys, files: files, closer: closer}, nil
+}
+
+// Open ouvre un fichier de l'export par son nom relatif (ex. "watched.csv", "lists/top.csv").
+func (e *letterboxdExport) Open(name string) (fs.File, error) {
+	p, ok := e.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return e.fsys.Open(p)
+}
+
+// Files renvoie, triés, les noms des fichiers de l'export situés dans le dossier dir.
+func (e *letterboxdExport) Files(dir string) []string {
+	var names []string
+	for name := range e.files {
+		if path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has indique si l'export contient le fichier donné.
+func (e *letterboxdExport) Has(name string) bool {
+	_, ok := e.files[name]
+	return ok
+}
+
+// Close libère l'archive ZIP le cas échéant.
+func (e *letterboxdExport) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// importExport importe tous les fichiers connus de l'export dans la transaction du run,
+// puis le JSON exporté par tmdb_call.go -output, s'il y en a un.
+func importExport(run *importRun, exp *letterboxdExport) error {
+	for _, source := range csvSources {
+		name := source + ".csv"
+		if !exp.Has(name) {
+			log.Printf("Fichier %s absent de l'export %s", name, exp.path)
+			continue
+		}
+		f, err := exp.Open(name)
+		if err != nil {
+			run.fail(name, err)
+			continue
+		}
+		stats, err := importCSV(run, f, name, source)
+		f.Close()
+		if err != nil {
+			run.fail(name, err)
+			continue
+		}
+		recordImportFile(run, name, stats)
+	}
+	importLists(run, exp)
+	importLikes(run, exp)
+
+	importTMDBFile(run, exp, "output.json", tmdbFile, importJSON)
+
+	return canonicalizeFilms(run.tx)
+}
+
+// importTMDBFile importe un fichier produit par tmdb_call.go, pris dans l'export s'il y est
+// présent ou à l'emplacement path sinon.
+func importTMDBFile(run *importRun, exp *letterboxdExport, name, path string,
+	importFile func(*importRun, io.Reader, string) (ImportStats, error)) {
+	var (
+		f   io.ReadCloser
+		err error
+	)
+	if exp.Has(name) {
+		f, err = exp.Open(name)
+	} else if path != "" {
+		name = path
+		f, err = os.Open(path)
+	}
+	switch {
+	case os.IsNotExist(err):
+		log.Printf("Fichier %s absent", name)
+	case err != nil:
+		run.fail(name, err)
+	case f != nil:
+		stats, err := importFile(run, f, name)
+		f.Close()
+		if err != nil {
+			run.fail(name, err)
+		} else {
+			recordImportFile(run, name, stats)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFindExportPath(t *testing.T) {
+	discardLog(t)
+	tests := []struct {
+		name    string
+		files   []string // Fichiers du dossier courant ; un nom finissant par / est un dossier
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{"argument", []string{"letterboxd-me-2025-04-12-09-30-utc.zip"}, "old.zip", "old.zip", false},
+		{"latest archive", []string{"letterboxd-me-2025-03-30-17-12-utc.zip", "letterboxd-me-2025-04-12-09-30-utc.zip"},
+			"", "letterboxd-me-2025-04-12-09-30-utc.zip", false},
+		{"archive over stats", []string{"stats/", "letterboxd-me-2025-03-30-17-12-utc.zip"},
+			"", "letterboxd-me-2025-03-30-17-12-utc.zip", false},
+		{"stats without archive", []string{"stats/", "export.zip"}, "", "stats", false},
+		{"nothing", []string{"export.zip"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, t.TempDir())
+			for _, name := range tt.files {
+				var err error
+				if dir, ok := strings.CutSuffix(name, "/"); ok {
+					err = os.Mkdir(dir, 0755)
+				} else {
+					err = os.WriteFile(name, nil, 0644)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := findExportPath(tt.arg)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("findExportPath(%q) = %q, %v; want %q, error %v", tt.arg, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// chdir change de dossier courant le temps d'un test.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestNewExport(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		want    []string // Noms relatifs à la racine de l'export
+		wantErr bool
+	}{
+		{"root", []string{"watched.csv", "diary.csv", "lists/top.csv"},
+			[]string{"diary.csv", "lists/top.csv", "watched.csv"}, false},
+		{"parent folder", []string{"letterboxd-me/watched.csv", "letterboxd-me/likes/films.csv", "other.csv"},
+			[]string{"likes/films.csv", "watched.csv"}, false},
+		{"deleted and orphaned ignored", []string{"watched.csv", "deleted/diary.csv", "orphaned/reviews.csv",
+			"__MACOSX/watched.csv"}, []string{"watched.csv"}, false},
+		{"other files ignored", []string{"watched.csv", "profile.txt"}, []string{"watched.csv"}, false},
+		{"not an export", []string{"diary.csv"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, name := range tt.files {
+				fsys[name] = &fstest.MapFile{Data: []byte("Date\n")}
+			}
+			exp, err := newExport("test", fsys, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("newExport accepted an export without watched.csv")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for name := range exp.files {
+				got = append(got, name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
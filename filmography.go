@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+)
+
+// shortMaxRuntime est la durée maximale d'un court métrage, en minutes.
+const shortMaxRuntime = 40
+
+// watchStatusSQL renvoie l'expression SQL du statut du film TMDB d'identifiant tmdbID (une
+// colonne) : seen s'il a été vu, watchlist s'il est dans la liste de films à voir, unseen sinon.
+func watchStatusSQL(tmdbID string) string {
+	return `CASE
+                WHEN EXISTS (SELECT 1 FROM movies m JOIN watched w ON w.letterboxd_uri = m.letterboxd_uri
+                    WHERE m.tmdb_id = ` + tmdbID + `) THEN 'seen'
+                WHEN EXISTS (SELECT 1 FROM movies m JOIN watchlist wl ON wl.letterboxd_uri = m.letterboxd_uri
+                    WHERE m.tmdb_id = ` + tmdbID + `) THEN 'watchlist'
+                ELSE 'unseen'
+            END`
+}
+
+// letterboxdURISQL renvoie l'expression SQL de l'URI Letterboxd du film TMDB d'identifiant
+// tmdbID, vide s'il n'est pas dans l'export.
+func letterboxdURISQL(tmdbID string) string {
+	return `IFNULL((SELECT m.letterboxd_uri FROM movies m WHERE m.tmdb_id = ` + tmdbID + ` LIMIT 1), '')`
+}
+
+// CompletionFilm est un film de la filmographie d'une personne, avec son statut : seen
+// (vu), watchlist (dans la liste de films à voir) ou unseen.
+type CompletionFilm struct {
+	TMDBID        int    `json:"tmdb_id" db:"tmdb_id"`
+	Title         string `json:"title" db:"title"`
+	ReleaseDate   string `json:"release_date" db:"release_date"`
+	Runtime       int    `json:"runtime" db:"runtime"`
+	Status        string `json:"status" db:"status"`
+	LetterboxdURI string `json:"letterboxd_uri,omitempty" db:"letterboxd_uri"`
+}
+
+// Completion indique combien de films de la filmographie d'une personne ont été vus.
+type Completion struct {
+	Person      store.Person     `json:"person"`
+	Role        string           `json:"role"`
+	FetchedAt   string           `json:"fetched_at"`
+	Total       int              `json:"total"`
+	Seen        int              `json:"seen"`
+	InWatchlist int              `json:"in_watchlist"`
+	Unseen      int              `json:"unseen"`
+	Ratio       float64          `json:"completion"`
+	Films       []CompletionFilm `json:"films"`
+}
+
+// completionHandler compare la filmographie d'une personne aux films vus. role restreint la
+// filmographie aux rôles d'acteur (actor) ou à un poste de /api/people/top ; exclude_shorts,
+// exclude_tv et exclude_uncredited écartent les courts métrages, les téléfilms et les
+// rôles non crédités.
+func completionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error": "Identifiant de personne invalide"}`, http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+
+	var c Completion
+	var fetchedAt sql.NullString
+	err = db.QueryRowx(`SELECT id, name, IFNULL(profile_path, ''), IFNULL(known_for_department, ''), filmography_fetched_at
+		FROM people WHERE id = ?`, id).Scan(&c.Person.ID, &c.Person.Name, &c.Person.ProfilePath, &c.Person.KnownForDepartment, &fetchedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Personne introuvable"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération de la personne"}`, http.StatusInternalServerError)
+		return
+	}
+	if !fetchedAt.Valid {
+		http.Error(w, `{"error": "Filmographie non récupérée, lancer tmdb_call.go -filmographies"}`, http.StatusNotFound)
+		return
+	}
+	c.FetchedAt = fetchedAt.String
+
+	where := []string{"f.person_id = ?"}
+	args := []interface{}{id}
+	c.Role = query.Get("role")
+	switch jobs, ok := crewRoles[c.Role]; {
+	case c.Role == "":
+	case c.Role == "actor":
+		where = append(where, "f.kind = 'cast'")
+	case ok:
+		in, inArgs, err := sqlx.In(`f.kind = 'crew' AND f.job IN (?)`, jobs)
+		if err != nil {
+			http.Error(w, `{"error": "Erreur lors du calcul de la filmographie"}`, http.StatusInternalServerError)
+			return
+		}
+		where = append(where, in)
+		args = append(args, inArgs...)
+	default:
+		http.Error(w, `{"error": "Rôle inconnu (director, cinematographer, composer ou actor)"}`, http.StatusBadRequest)
+		return
+	}
+	if query.Get("exclude_shorts") == "true" {
+		where = append(where, fmt.Sprintf("NOT (f.runtime > 0 AND f.runtime <= %d)", shortMaxRuntime))
+	}
+	if query.Get("exclude_tv") == "true" {
+		where = append(where, "f.tv_movie = 0")
+	}
+	if query.Get("exclude_uncredited") == "true" {
+		where = append(where, "f.uncredited = 0")
+	}
+
+	c.Films = []CompletionFilm{}
+	err = db.Select(&c.Films, `
+        SELECT f.tmdb_id, MAX(IFNULL(f.title, '')) AS title, MAX(IFNULL(f.release_date, '')) AS release_date,
+            MAX(IFNULL(f.runtime, 0)) AS runtime,
+            `+watchStatusSQL("f.tmdb_id")+` AS status,
+            `+letterboxdURISQL("f.tmdb_id")+` AS letterboxd_uri
+        FROM person_filmography f
+        WHERE `+strings.Join(where, " AND ")+`
+        GROUP BY f.tmdb_id
+        ORDER BY release_date = '', release_date, title
+    `, args...)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors du calcul de la filmographie"}`, http.StatusInternalServerError)
+		return
+	}
+
+	for _, film := range c.Films {
+		switch film.Status {
+		case "seen":
+			c.Seen++
+		case "watchlist":
+			c.InWatchlist++
+		default:
+			c.Unseen++
+		}
+	}
+	c.Total = len(c.Films)
+	if c.Total > 0 {
+		c.Ratio = float64(c.Seen) / float64(c.Total)
+	}
+
+	json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// entrySources liste les fichiers dont la colonne "Letterboxd URI" pointe vers une entrée
+// de journal (critique, visionnage) et non vers le film lui-même. Les listes donnent l'URL
+// longue du film, différente du lien court boxd.it de watched.csv : elles sont résolues de
+// la même façon.
+var entrySources = map[string]bool{
+	"diary":    true,
+	"reviews":  true,
+	"comments": true,
+	"lists":    true,
+}
+
+// filmTables liste les tables filles qui référencent un film canonique via letterboxd_uri.
+var filmTables = []string{"watched", "watchlist", "ratings", "diary", "reviews", "comments", "list_entries", "likes",
+	"movie_genres", "movie_countries", "movie_cast", "movie_crew", "movie_keywords", "movie_companies",
+	"movie_spoken_languages"}
+
+// entryURIPattern reconnaît les URI de la forme https://letterboxd.com/<membre>/film/<slug>/...
+// (entrée de journal) ou https://letterboxd.com/film/<slug>/ (film, dans les listes),
+// dont on peut déduire l'URI du film.
+var entryURIPattern = regexp.MustCompile(`^https?://(?:www\.)?letterboxd\.com/(?:[^/]+/)?film/([^/]+)/?`)
+
+// canonicalFromPattern déduit l'URI du film d'une URI d'entrée, ou renvoie "" si l'URI
+// ne suit pas le motif (liens courts boxd.it notamment).
+func canonicalFromPattern(uri string) string {
+	m := entryURIPattern.FindStringSubmatch(uri)
+	if m == nil {
+		return ""
+	}
+	return "https://letterboxd.com/film/" + m[1] + "/"
+}
+
+// mapFilmURI enregistre le rattachement d'une URI à un film canonique.
+func mapFilmURI(db dbtx, uri, filmURI, name string, year int, method string) error {
+	_, err := db.Exec(`INSERT INTO film_uris (uri, film_uri, name, year, method) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (uri) DO UPDATE SET film_uri = excluded.film_uri, method = excluded.method`,
+		uri, filmURI, name, year, method)
+	return err
+}
+
+// resolveFilm rattache une URI lue dans l'export à son film canonique, crée le film s'il
+// n'existe pas et renvoie son URI. Les URI issues de watched, watchlist et ratings sont des
+// URI de films ; celles des critiques et commentaires sont résolues par motif d'URI, puis
+// par nom et année parmi les films déjà connus.
+//
+// Un film créé pour une entrée se référence avec la méthode "entry" : c'est le seul que
+// SaveMovie peut fusionner avec un autre film de même identifiant TMDB. Il devient un film
+// ("film") dès qu'il apparaît dans watched, watchlist, ratings ou les films aimés.
+func resolveFilm(db dbtx, uri, name string, year int, source string) (string, error) {
+	var known struct {
+		FilmURI string `db:"film_uri"`
+		Method  string `db:"method"`
+	}
+	err := db.Get(&known, `SELECT film_uri, IFNULL(method, '') AS method FROM film_uris WHERE uri = ?`, uri)
+	if err == nil {
+		if known.Method == "entry" && !entrySources[source] {
+			if _, err := db.Exec(`UPDATE film_uris SET method = 'film' WHERE uri = ?`, uri); err != nil {
+				return "", err
+			}
+		}
+		return known.FilmURI, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	filmURI, method, self := uri, "film", "film"
+	if entrySources[source] {
+		if filmURI, method, err = matchEntry(db, uri, name, year); err != nil {
+			return "", err
+		}
+		self = "entry"
+	}
+
+	if err := getOrCreateMovie(db, name, year, filmURI); err != nil {
+		return "", err
+	}
+	// Le film canonique se référence lui-même pour être retrouvé par nom et année
+	if _, err := db.Exec(`INSERT OR IGNORE INTO film_uris (uri, film_uri, name, year, method) VALUES (?, ?, ?, ?, ?)`,
+		filmURI, filmURI, name, year, self); err != nil {
+		return "", err
+	}
+	if uri != filmURI {
+		if err := mapFilmURI(db, uri, filmURI, name, year, method); err != nil {
+			return "", err
+		}
+	}
+	return filmURI, nil
+}
+
+// matchEntry cherche le film d'une URI d'entrée et renvoie son URI avec la méthode utilisée.
+func matchEntry(db dbtx, uri, name string, year int) (string, string, error) {
+	candidate := canonicalFromPattern(uri)
+	if candidate != "" {
+		var known string
+		err := db.Get(&known, `SELECT film_uri FROM film_uris WHERE uri = ?`, candidate)
+		if err == nil {
+			return known, "pattern", nil
+		}
+		if err != sql.ErrNoRows {
+			return "", "", err
+		}
+	}
+
+	var matches []string
+	err := db.Select(&matches, `SELECT DISTINCT film_uri FROM film_uris WHERE name = ? AND year = ?`, name, year)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case len(matches) == 1:
+		if candidate != "" {
+			if err := mapFilmURI(db, candidate, matches[0], name, year, "pattern"); err != nil {
+				return "", "", err
+			}
+		}
+		return matches[0], "name_year", nil
+	case len(matches) > 1:
+		log.Printf("Plusieurs films correspondent à %s (%d), entrée %s non rattachée", name, year, uri)
+	}
+
+	if candidate != "" {
+		return candidate, "pattern", nil
+	}
+	return uri, "unresolved", nil
+}
+
+// canonicalizeFilms fait pointer toutes les tables filles vers les films canoniques et
+// supprime les films fantômes créés pour des URI d'entrée par les anciens imports.
+func canonicalizeFilms(db dbtx) error {
+	const aliases = `SELECT uri FROM film_uris WHERE uri != film_uri`
+	for _, table := range filmTables {
+		// Une ligne déjà présente sur le film canonique fait doublon : elle est supprimée
+		queries := []string{
+			fmt.Sprintf(`UPDATE OR IGNORE %s SET letterboxd_uri = (SELECT film_uri FROM film_uris WHERE uri = %s.letterboxd_uri)
+				WHERE letterboxd_uri IN (%s)`, table, table, aliases),
+			fmt.Sprintf(`DELETE FROM %s WHERE letterboxd_uri IN (%s)`, table, aliases),
+		}
+		for _, query := range queries {
+			if _, err := db.Exec(query); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := db.Exec(`DELETE FROM movies WHERE letterboxd_uri IN (` + aliases + `)`)
+	return err
+}
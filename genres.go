@@ -0,0 +1,30 @@
+package main
+
+// GenreStat résume les films vus d'un genre : nombre de films, note moyenne donnée et
+// durée totale.
+type GenreStat struct {
+	Genre         string  `json:"genre" db:"genre"`
+	Count         int     `json:"count" db:"count"`
+	AverageRating float64 `json:"average_rating" db:"average_rating"`
+	TotalRuntime  int     `json:"total_runtime" db:"total_runtime"`
+}
+
+// genreStats calcule, pour chaque genre, le nombre de films vus, la note moyenne donnée à
+// ces films et leur durée totale. Un film compte dans chacun de ses genres.
+func genreStats() ([]GenreStat, error) {
+	stats := []GenreStat{}
+	err := db.Select(&stats, `
+        SELECT g.name AS genre,
+            COUNT(*) AS count,
+            IFNULL(AVG(r.rating), 0) AS average_rating,
+            IFNULL(SUM(m.runtime), 0) AS total_runtime
+        FROM (SELECT DISTINCT letterboxd_uri FROM watched) w
+        JOIN movies m ON m.letterboxd_uri = w.letterboxd_uri
+        JOIN movie_genres mg ON mg.letterboxd_uri = w.letterboxd_uri
+        JOIN genres g ON g.id = mg.genre_id
+        LEFT JOIN ratings r ON r.letterboxd_uri = w.letterboxd_uri
+        GROUP BY g.id
+        ORDER BY count DESC, genre
+    `)
+	return stats, err
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	uploadDir      = "uploads"
+	maxUploadSize  = 256 << 20 // Les exports font quelques Mo, même pour un gros journal
+	importQueueLen = 8
+)
+
+// tmdbFile est l'emplacement d'un JSON exporté par tmdb_call.go -output, importé après les
+// CSV quand l'export n'en contient pas. tmdb_call.go écrit directement dans movies.db : ce
+// fichier ne sert qu'à importer des films récupérés ailleurs.
+var tmdbFile string
+
+// importJob est un export envoyé sur /api/imports en attente d'import.
+type importJob struct {
+	runID int64
+	path  string
+}
+
+// importQueue reçoit les imports envoyés ; importWorker les traite un par un.
+var importQueue = make(chan importJob, importQueueLen)
+
+// runImport importe l'export dans une seule transaction : tant qu'elle n'est pas validée,
+// /api/movies et /api/statistics continuent de servir les données précédentes.
+func runImport(db *sqlx.DB, runID int64, exp *letterboxdExport) error {
+	run := &importRun{id: runID}
+	if _, err := db.Exec(`UPDATE import_runs SET status = 'running' WHERE id = ?`, runID); err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err == nil {
+		run.tx = tx
+		run.stmts = newStmtCache(tx)
+		defer run.stmts.Close()
+		if err = importExport(run, exp); err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}
+
+	status := "done"
+	if err != nil {
+		status = "failed"
+		run.report(ImportIssue{File: exp.path, Problem: problemDatabaseError, Action: actionRunAborted,
+			Message: fmt.Sprintf("import annulé: %v", err)})
+	}
+	if ferr := finishImportRun(db, run, status); ferr != nil {
+		log.Println("Erreur lors de la clôture du run d'import:", ferr)
+	}
+	return err
+}
+
+// importWorker traite les imports en file d'attente. Un import réussi devient l'export
+// servi par /api/data ; l'archive d'un import en échec, comme celle de l'export remplacé,
+// est supprimée du dossier uploads.
+func importWorker(db *sqlx.DB) {
+	for job := range importQueue {
+		processImportJob(db, job)
+	}
+}
+
+// processImportJob importe une archive de la file d'attente.
+func processImportJob(db *sqlx.DB, job importJob) {
+	exp, err := openExport(job.path)
+	if err != nil {
+		failImportRun(db, job.runID, job.path, err)
+		removeUpload(job.path)
+		return
+	}
+	if err := runImport(db, job.runID, exp); err != nil {
+		log.Printf("Erreur lors de l'import %d: %v", job.runID, err)
+		exp.Close()
+		removeUpload(job.path)
+		return
+	}
+	if previous := setExport(exp); previous != "" {
+		removeUpload(previous)
+	}
+}
+
+// removeUpload supprime une archive reçue sur /api/imports. Les exports pris ailleurs
+// (celui du démarrage notamment) ne sont jamais supprimés.
+func removeUpload(path string) {
+	if filepath.Dir(path) != filepath.Clean(uploadDir) {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Impossible de supprimer l'export %s: %v", path, err)
+	}
+}
+
+// failImportRun clôture en échec un run qui n'a pas pu démarrer.
+func failImportRun(db *sqlx.DB, runID int64, file string, err error) {
+	run := &importRun{id: runID}
+	run.report(ImportIssue{File: file, Problem: problemImportError, Action: actionRunAborted, Message: err.Error()})
+	if ferr := finishImportRun(db, run, "failed"); ferr != nil {
+		log.Println("Erreur lors de la clôture du run d'import:", ferr)
+	}
+}
+
+// createImportHandler reçoit une archive d'export Letterboxd, en champ "file" d'un
+// formulaire multipart ou en corps brut, et la met en file d'attente d'import.
+func createImportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, `{"error": "Champ file manquant"}`, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		http.Error(w, `{"error": "Impossible d'enregistrer l'export"}`, http.StatusInternalServerError)
+		return
+	}
+	f, err := os.CreateTemp(uploadDir, "letterboxd-*.zip")
+	if err != nil {
+		http.Error(w, `{"error": "Impossible d'enregistrer l'export"}`, http.StatusInternalServerError)
+		return
+	}
+	_, err = io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		http.Error(w, `{"error": "Erreur lors de la réception de l'export"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Vérifier dès maintenant qu'il s'agit bien d'un export Letterboxd
+	exp, err := openExport(f.Name())
+	if err != nil {
+		log.Printf("Export reçu refusé: %v", err)
+		os.Remove(f.Name())
+		http.Error(w, `{"error": "Le fichier n'est pas un export Letterboxd"}`, http.StatusBadRequest)
+		return
+	}
+	exp.Close()
+
+	runID, err := startImportRun(db, f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		http.Error(w, `{"error": "Erreur lors de la création de l'import"}`, http.StatusInternalServerError)
+		return
+	}
+	select {
+	case importQueue <- importJob{runID: runID, path: f.Name()}:
+	default:
+		failImportRun(db, runID, f.Name(), fmt.Errorf("file d'attente des imports pleine"))
+		os.Remove(f.Name())
+		http.Error(w, `{"error": "Trop d'imports en attente, réessayer plus tard"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/imports/%d", runID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": runID, "status": "queued"})
+}
+
+// importRunHandler renvoie l'état d'un import : statut, compteurs par fichier et rapport.
+func importRunHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error": "Identifiant d'import invalide"}`, http.StatusBadRequest)
+		return
+	}
+
+	runs := []ImportRun{}
+	err = db.Select(&runs, `SELECT id, IFNULL(started_at, '') AS started_at,
+		IFNULL(finished_at, '') AS finished_at, IFNULL(status, '') AS status, IFNULL(source, '') AS source
+		FROM import_runs WHERE id = ?`, id)
+	if err == nil {
+		err = loadImportDetails(runs)
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération de l'import"}`, http.StatusInternalServerError)
+		return
+	}
+	if len(runs) == 0 {
+		http.Error(w, `{"error": "Import introuvable"}`, http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(runs[0])
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testZip construit une archive à partir du contenu de ses fichiers.
+func testZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// multipartBody place data dans le champ field d'un formulaire multipart.
+func multipartBody(t *testing.T, field string, data []byte) (io.Reader, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	w, err := mw.CreateFormFile(field, "letterboxd-me-2025-04-12-09-30-utc.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, mw.FormDataContentType()
+}
+
+func TestCreateImport(t *testing.T) {
+	discardLog(t)
+	chdir(t, t.TempDir())
+	testDB := openTestDB(t)
+	useTestDB(t, testDB)
+	t.Cleanup(closeExport)
+
+	archive := testZip(t, map[string]string{
+		"watched.csv": "Date,Name,Year,Letterboxd URI\n2024-01-02,Alien,1979,https://boxd.it/2b0k\n",
+	})
+	tests := []struct {
+		name       string
+		body       func() (io.Reader, string) // Corps de la requête et son Content-Type
+		wantStatus int
+		wantError  string
+	}{
+		{"raw archive", func() (io.Reader, string) { return bytes.NewReader(archive), "application/zip" },
+			http.StatusAccepted, ""},
+		{"multipart archive", func() (io.Reader, string) { return multipartBody(t, "file", archive) },
+			http.StatusAccepted, ""},
+		{"multipart without file", func() (io.Reader, string) { return multipartBody(t, "export", archive) },
+			http.StatusBadRequest, "Champ file manquant"},
+		{"not an archive", func() (io.Reader, string) { return strings.NewReader("Date,Name\n"), "text/csv" },
+			http.StatusBadRequest, "Le fichier n'est pas un export Letterboxd"},
+		{"archive without watched.csv", func() (io.Reader, string) {
+			return bytes.NewReader(testZip(t, map[string]string{"diary.csv": "Date\n"})), "application/zip"
+		}, http.StatusBadRequest, "Le fichier n'est pas un export Letterboxd"},
+	}
+	var lastUpload string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := tt.body()
+			req := httptest.NewRequest(http.MethodPost, "/api/imports", body)
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			createImportHandler(rec, req)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantError) {
+				t.Fatalf("status %d: %s; want %d %q", rec.Code, rec.Body, tt.wantStatus, tt.wantError)
+			}
+			if rec.Code != http.StatusAccepted {
+				return
+			}
+
+			job := <-importQueue
+			processImportJob(testDB, job)
+			var status string
+			if err := testDB.Get(&status, `SELECT status FROM import_runs WHERE id = ?`, job.runID); err != nil {
+				t.Fatal(err)
+			}
+			if status != "done" {
+				t.Errorf("import %d %s, want done", job.runID, status)
+			}
+			if n := countRows(t, testDB)["watched"]; n != 1 {
+				t.Errorf("%d watched rows, want 1", n)
+			}
+			lastUpload = job.path
+		})
+	}
+
+	// Seule l'archive de l'export servi reste dans uploads : celle de l'export remplacé et
+	// les fichiers refusés sont supprimés
+	uploads, err := filepath.Glob(filepath.Join(uploadDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads[0] != lastUpload {
+		t.Errorf("uploads = %v, want only %s", uploads, lastUpload)
+	}
+	if _, err := os.Stat(lastUpload); err != nil {
+		t.Error(err)
+	}
+}
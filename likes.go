@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// Like représente un « j'aime » Letterboxd sur un film, une critique ou une liste.
+// Pour une critique, LetterboxdURI est le film critiqué ; une liste n'a pas de film.
+type Like struct {
+	ID            int    `db:"id"`
+	Kind          string `db:"kind"` // film, review ou list
+	LetterboxdURI string `db:"letterboxd_uri"`
+	TargetURI     string `db:"target_uri"` // URI de l'élément aimé, telle qu'écrite dans l'export
+	Name          string `db:"name"`
+	LikedDate     string `db:"liked_date"`
+}
+
+// LikeStat compare, pour un groupe de films vus (décennie, pays...), les films aimés
+// et les notes données.
+type LikeStat struct {
+	Group              string  `json:"group" db:"grp"`
+	Watched            int     `json:"watched" db:"watched"`
+	Liked              int     `json:"liked" db:"liked"`
+	LikeRatio          float64 `json:"like_ratio" db:"like_ratio"`
+	AverageRating      float64 `json:"average_rating" db:"average_rating"`
+	LikedAverageRating float64 `json:"liked_average_rating" db:"liked_average_rating"`
+}
+
+// likeFiles associe chaque fichier du dossier likes/ au type d'élément aimé.
+var likeFiles = []struct{ name, kind string }{
+	{"likes/films.csv", "film"},
+	{"likes/reviews.csv", "review"},
+	{"likes/lists.csv", "list"},
+}
+
+// importLikes importe les fichiers du dossier likes/ de l'export.
+func importLikes(run *importRun, exp *letterboxdExport) {
+	for _, lf := range likeFiles {
+		if !exp.Has(lf.name) {
+			continue
+		}
+		f, err := exp.Open(lf.name)
+		if err != nil {
+			run.fail(lf.name, err)
+			continue
+		}
+		stats, err := importLikeFile(run, f, lf.name, lf.kind)
+		f.Close()
+		if err != nil {
+			run.fail(lf.name, err)
+			continue
+		}
+		recordImportFile(run, lf.name, stats)
+	}
+}
+
+// importLikeFile importe un fichier de likes, ligne à ligne, dans un point de sauvegarde du
+// run. Les films aimés sont des URI de films ; les critiques aimées sont rattachées au film
+// critiqué comme les critiques de l'export. Les likes de ce type absents du fichier, retirés
+// depuis l'export précédent, sont supprimés comme les lignes des CSV (voir csvSnapshots).
+func importLikeFile(run *importRun, r io.Reader, name, kind string) (ImportStats, error) {
+	var stats ImportStats
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return stats, nil // Pas de données
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	colIdx := columnIndex(header)
+	// Les likes de listes donnent l'adresse de la liste dans une colonne URL
+	uriColumn := "Letterboxd URI"
+	if _, ok := colIdx[uriColumn]; !ok {
+		if _, ok := colIdx["URL"]; ok {
+			uriColumn = "URL"
+		}
+	}
+	if missing := missingColumns(colIdx, "Date", "Name", uriColumn); len(missing) > 0 {
+		return stats, &missingColumnsError{line: 1, columns: missing}
+	}
+	fieldCount := len(header)
+	seen := make(map[string]bool)
+	rejected := 0
+
+	err = run.withSavepoint(func() error {
+		stmt, err := prepareUpsert(run.tx, `INSERT INTO likes (kind, letterboxd_uri, target_uri, name, liked_date)
+			VALUES (:kind, NULLIF(:letterboxd_uri, ''), :target_uri, :name, :liked_date)
+			ON CONFLICT (kind, target_uri) DO NOTHING`,
+			`UPDATE likes SET letterboxd_uri = NULLIF(:letterboxd_uri, ''), name = :name, liked_date = :liked_date
+			WHERE kind = :kind AND target_uri = :target_uri
+			AND (letterboxd_uri IS NOT NULLIF(:letterboxd_uri, '') OR name IS NOT :name OR liked_date IS NOT :liked_date)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.Is(err, csv.ErrFieldCount) && errors.As(err, &parseErr) {
+				run.report(ImportIssue{File: name, Row: parseErr.StartLine, Problem: problemFieldCount, Action: actionRowSkipped,
+					Message: fmt.Sprintf("%d champs au lieu de %d", len(record), fieldCount)})
+				rejected++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			line, _ := reader.FieldPos(0)
+			row := csvRow{run: run, file: name, line: line, colIdx: colIdx, fields: record}
+
+			like := Like{
+				Kind:      kind,
+				TargetURI: row.get(uriColumn),
+				Name:      row.get("Name"),
+				LikedDate: row.get("Date"),
+			}
+			if like.TargetURI == "" {
+				row.issue(uriColumn, problemMissingValue, actionRowSkipped, "URI de l'élément aimé vide")
+				rejected++
+				continue
+			}
+			if kind != "list" {
+				source := "likes"
+				if kind == "review" {
+					source = "reviews"
+				}
+				if like.LetterboxdURI, err = run.resolveFilm(like.TargetURI, like.Name, row.year(), source); err != nil {
+					return &importError{row: line, problem: problemDatabaseError, err: fmt.Errorf("film %s: %w", like.Name, err)}
+				}
+			}
+
+			outcome, err := stmt.exec(like)
+			if err != nil {
+				return &importError{row: line, problem: problemDatabaseError, err: fmt.Errorf("insertion dans likes: %w", err)}
+			}
+			stats.add(outcome)
+			seen[like.TargetURI] = true
+		}
+
+		// Une ligne rejetée peut être celle d'un like en base : rien n'est supprimé
+		if rejected > 0 {
+			log.Printf("%s: %d ligne(s) rejetée(s), les likes absents du fichier sont conservés", name, rejected)
+			return nil
+		}
+		stats.Removed, err = removeMissing(run, "likes", `SELECT id, target_uri AS key FROM likes WHERE kind = ?`, seen, kind)
+		return err
+	})
+	if err != nil {
+		return ImportStats{}, err
+	}
+	return stats, nil
+}
+
+// likeStats calcule, pour les films vus regroupés selon groupExpr (expression SQL sur le
+// film m et les tables jointes par join), la part de films aimés et la note moyenne,
+// globale et des seuls films aimés.
+func likeStats(join, groupExpr string) ([]LikeStat, error) {
+	stats := []LikeStat{}
+	err := db.Select(&stats, `
+        SELECT `+groupExpr+` AS grp,
+            COUNT(*) AS watched,
+            COUNT(l.letterboxd_uri) AS liked,
+            CAST(COUNT(l.letterboxd_uri) AS REAL) / COUNT(*) AS like_ratio,
+            IFNULL(AVG(r.rating), 0) AS average_rating,
+            IFNULL(AVG(CASE WHEN l.letterboxd_uri IS NOT NULL THEN r.rating END), 0) AS liked_average_rating
+        FROM (SELECT DISTINCT letterboxd_uri FROM watched) w
+        JOIN movies m ON m.letterboxd_uri = w.letterboxd_uri
+        `+join+`
+        LEFT JOIN (SELECT DISTINCT letterboxd_uri FROM likes WHERE kind = 'film') l ON l.letterboxd_uri = w.letterboxd_uri
+        LEFT JOIN ratings r ON r.letterboxd_uri = w.letterboxd_uri
+        WHERE grp != ''
+        GROUP BY grp
+        ORDER BY watched DESC
+    `)
+	return stats, err
+}
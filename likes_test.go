@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestImportLikes(t *testing.T) {
+	discardLog(t)
+	const watched = "Date,Name,Year,Letterboxd URI\n2024-01-02,Alien,1979,https://boxd.it/2b0k\n2024-01-03,Brazil,1985,https://boxd.it/29Nu\n"
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []Like // Sans identifiant, dans l'ordre de kind puis target_uri
+	}{
+		{"films", map[string]string{
+			"likes/films.csv": "Date,Name,Year,Letterboxd URI\n2024-02-01,Alien,1979,https://boxd.it/2b0k\n",
+		}, []Like{{Kind: "film", LetterboxdURI: "https://boxd.it/2b0k", TargetURI: "https://boxd.it/2b0k", Name: "Alien", LikedDate: "2024-02-01"}}},
+		{"reviews", map[string]string{
+			"likes/reviews.csv": "Date,Name,Year,Letterboxd URI\n2024-02-02,Brazil,1985,https://letterboxd.com/friend/film/brazil/\n",
+		}, []Like{{Kind: "review", LetterboxdURI: "https://boxd.it/29Nu", TargetURI: "https://letterboxd.com/friend/film/brazil/", Name: "Brazil", LikedDate: "2024-02-02"}}},
+		{"lists", map[string]string{
+			"likes/lists.csv": "Date,Name,URL\n2024-02-03,Best of 1979,https://letterboxd.com/friend/list/best-of-1979/\n",
+		}, []Like{{Kind: "list", TargetURI: "https://letterboxd.com/friend/list/best-of-1979/", Name: "Best of 1979", LikedDate: "2024-02-03"}}},
+		{"missing URI", map[string]string{
+			"likes/films.csv": "Date,Name,Year,Letterboxd URI\n2024-02-01,Alien,1979,\n2024-02-04,Brazil,1985,https://boxd.it/29Nu\n",
+		}, []Like{{Kind: "film", LetterboxdURI: "https://boxd.it/29Nu", TargetURI: "https://boxd.it/29Nu", Name: "Brazil", LikedDate: "2024-02-04"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := openTestDB(t)
+			tt.files["watched.csv"] = watched
+			importTestExport(t, testDB, testExport(t, tt.files))
+
+			var got []Like
+			if err := testDB.Select(&got, `SELECT 0 AS id, kind, IFNULL(letterboxd_uri, '') AS letterboxd_uri, target_uri,
+				name, liked_date FROM likes ORDER BY kind, target_uri`); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("likes = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("like %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLikeStats(t *testing.T) {
+	discardLog(t)
+	testDB := openTestDB(t)
+	useTestDB(t, testDB)
+	importTestExport(t, testDB, testExport(t, map[string]string{
+		"watched.csv": "Date,Name,Year,Letterboxd URI\n2024-01-02,Alien,1979,https://boxd.it/2b0k\n" +
+			"2024-01-03,Stalker,1979,https://boxd.it/2aEe\n2024-01-04,Brazil,1985,https://boxd.it/29Nu\n",
+		"ratings.csv": "Date,Name,Year,Letterboxd URI,Rating\n2024-01-02,Alien,1979,https://boxd.it/2b0k,5\n" +
+			"2024-01-03,Stalker,1979,https://boxd.it/2aEe,3\n2024-01-04,Brazil,1985,https://boxd.it/29Nu,4\n",
+		"likes/films.csv": "Date,Name,Year,Letterboxd URI\n2024-02-01,Alien,1979,https://boxd.it/2b0k\n",
+	}))
+
+	stats, err := likeStats("", `CAST(m.year / 10 * 10 AS TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LikeStat{
+		{Group: "1970", Watched: 2, Liked: 1, LikeRatio: 0.5, AverageRating: 4, LikedAverageRating: 5},
+		{Group: "1980", Watched: 1, Liked: 0, LikeRatio: 0, AverageRating: 4, LikedAverageRating: 0},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("likeStats = %+v, want %+v", stats, want)
+	}
+	for i := range stats {
+		if stats[i] != want[i] {
+			t.Errorf("group %d = %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+)
+
+// List représente une liste Letterboxd issue du dossier lists/ de l'export.
+type List struct {
+	ID          int64       `json:"id" db:"id"`
+	URI         string      `json:"uri" db:"uri"`
+	Name        string      `json:"name" db:"name"`
+	Description string      `json:"description" db:"description"`
+	Tags        string      `json:"tags" db:"tags"`
+	CreatedDate string      `json:"created_date" db:"created_date"`
+	EntryCount  int         `json:"entry_count" db:"entry_count"`
+	Entries     []ListEntry `json:"entries,omitempty" db:"-"`
+}
+
+// ListEntry représente un film d'une liste, à sa position, avec la note de l'auteur.
+// Movie.LetterboxdURI porte le film canonique.
+type ListEntry struct {
+	ListID      int64  `json:"-" db:"list_id"`
+	Position    int    `json:"position" db:"position"`
+	EntryURI    string `json:"url" db:"entry_uri"` // URL du film telle qu'écrite dans la liste
+	Note        string `json:"note" db:"note"`
+	store.Movie `json:"movie"`
+}
+
+// importList importe un fichier lists/*.csv et renvoie l'URI de la liste. Ces fichiers ont
+// deux sections : un en-tête décrivant la liste (Date, Name, Tags, URL, Description) puis les
+// films classés (Position, Name, Year, URL, Description).
+func importList(run *importRun, r io.Reader, name string) (string, ImportStats, error) {
+	var stats ImportStats
+	db := run.tx
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // La première ligne ("Letterboxd list export v7") n'a qu'un champ
+	reader.TrimLeadingSpace = true
+
+	var (
+		list    List
+		entries []ListEntry
+		titles  []string
+		years   []int
+		colIdx  map[string]int
+		section string
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", stats, err
+		}
+		line, _ := reader.FieldPos(0)
+		switch record[0] {
+		case "Date", "Position":
+			section = record[0]
+			colIdx = columnIndex(record)
+			required := []string{"Name"}
+			if section == "Position" {
+				required = []string{"Position", "Name", "Year", "URL"}
+			}
+			if missing := missingColumns(colIdx, required...); len(missing) > 0 {
+				return "", stats, &missingColumnsError{line: line, columns: missing}
+			}
+			continue
+		}
+		row := csvRow{run: run, file: name, line: line, colIdx: colIdx, fields: record}
+		switch section {
+		case "Date":
+			list.CreatedDate = row.get("Date")
+			list.Name = row.get("Name")
+			list.Tags = row.get("Tags")
+			list.URI = row.get("URL")
+			list.Description = row.get("Description")
+		case "Position":
+			position, err := strconv.Atoi(row.get("Position"))
+			if err != nil {
+				row.issue("Position", problemInvalidPosition, actionRowSkipped, "position %q invalide pour %s", row.get("Position"), row.get("Name"))
+				continue
+			}
+			entries = append(entries, ListEntry{Position: position, EntryURI: row.get("URL"), Note: row.get("Description")})
+			titles = append(titles, row.get("Name"))
+			years = append(years, row.year())
+		}
+	}
+	if list.Name == "" {
+		return "", stats, &importError{problem: problemMissingHeader, err: fmt.Errorf("en-tête de liste introuvable")}
+	}
+	if list.URI == "" {
+		// Sans URL, le nom du fichier identifie la liste d'un export à l'autre
+		list.URI = name
+	}
+
+	err := run.withSavepoint(func() error {
+		if _, err := db.NamedExec(`INSERT INTO lists (uri, name, description, tags, created_date)
+			VALUES (:uri, :name, :description, :tags, :created_date)
+			ON CONFLICT (uri) DO UPDATE SET name = excluded.name, description = excluded.description,
+				tags = excluded.tags, created_date = excluded.created_date`, list); err != nil {
+			return err
+		}
+		if err := db.Get(&list.ID, `SELECT id FROM lists WHERE uri = ?`, list.URI); err != nil {
+			return err
+		}
+
+		stmt, err := prepareUpsert(db, `INSERT INTO list_entries (list_id, position, letterboxd_uri, entry_uri, note)
+			VALUES (:list_id, :position, :letterboxd_uri, :entry_uri, :note)
+			ON CONFLICT (list_id, position) DO NOTHING`,
+			`UPDATE list_entries SET letterboxd_uri = :letterboxd_uri, entry_uri = :entry_uri, note = :note
+			WHERE list_id = :list_id AND position = :position
+			AND (letterboxd_uri IS NOT :letterboxd_uri OR entry_uri IS NOT :entry_uri OR note IS NOT :note)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		last := 0 // Plus grande position écrite
+		for i, entry := range entries {
+			entry.ListID = list.ID
+			entry.Movie.LetterboxdURI, err = run.resolveFilm(entry.EntryURI, titles[i], years[i], "lists")
+			if err != nil {
+				return &importError{problem: problemDatabaseError, err: fmt.Errorf("film %s: %w", titles[i], err)}
+			}
+			outcome, err := stmt.exec(entry)
+			if err != nil {
+				return &importError{problem: problemDatabaseError, err: fmt.Errorf("position %d, insertion dans list_entries: %w", entry.Position, err)}
+			}
+			stats.add(outcome)
+			last = max(last, entry.Position)
+		}
+
+		// Les films retirés de la fin de la liste depuis le dernier export disparaissent. Les
+		// positions des lignes rejetées ne sont pas comptées : len(entries) peut être inférieur
+		// à la dernière position.
+		res, err := db.Exec(`DELETE FROM list_entries WHERE list_id = ? AND position > ?`, list.ID, last)
+		if err != nil {
+			return err
+		}
+		removed, err := res.RowsAffected()
+		stats.Removed = int(removed)
+		return err
+	})
+	if err != nil {
+		return "", ImportStats{}, err
+	}
+	return list.URI, stats, nil
+}
+
+// importLists importe chaque fichier du dossier lists/ de l'export, puis supprime les listes
+// qui n'y sont plus, supprimées sur Letterboxd depuis l'export précédent.
+func importLists(run *importRun, exp *letterboxdExport) {
+	seen := make(map[string]bool)
+	failed := 0
+	for _, name := range exp.Files("lists") {
+		if path.Ext(name) != ".csv" {
+			continue
+		}
+		f, err := exp.Open(name)
+		if err != nil {
+			run.fail(name, err)
+			failed++
+			continue
+		}
+		uri, stats, err := importList(run, f, name)
+		f.Close()
+		if err != nil {
+			run.fail(name, err)
+			failed++
+			continue
+		}
+		seen[uri] = true
+		recordImportFile(run, name, stats)
+	}
+
+	// Une liste dont le fichier n'a pas pu être importé n'est pas forcément supprimée
+	if failed > 0 {
+		log.Printf("lists: %d fichier(s) en échec, les listes absentes de l'export sont conservées", failed)
+		return
+	}
+	var stats ImportStats
+	err := run.withSavepoint(func() error {
+		var err error
+		stats.Removed, err = removeMissing(run, "lists", `SELECT id, uri AS key FROM lists`, seen)
+		if err != nil {
+			return err
+		}
+		_, err = run.tx.Exec(`DELETE FROM list_entries WHERE list_id NOT IN (SELECT id FROM lists)`)
+		return err
+	})
+	switch {
+	case err != nil:
+		run.fail("lists", err)
+	case stats.Removed > 0:
+		recordImportFile(run, "lists", stats)
+	}
+}
+
+// listsHandler renvoie les listes importées avec leur nombre de films.
+func listsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	lists := []List{}
+	err := db.Select(&lists, `SELECT l.id, l.uri, IFNULL(l.name, '') AS name,
+		IFNULL(l.description, '') AS description, IFNULL(l.tags, '') AS tags,
+		IFNULL(l.created_date, '') AS created_date, COUNT(e.position) AS entry_count
+		FROM lists l
+		LEFT JOIN list_entries e ON e.list_id = l.id
+		GROUP BY l.id
+		ORDER BY l.created_date DESC`)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération des listes"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(lists)
+}
+
+// listHandler renvoie une liste et ses films dans l'ordre, chacun joint à sa ligne movies.
+func listHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error": "Identifiant de liste invalide"}`, http.StatusBadRequest)
+		return
+	}
+
+	var list List
+	err = db.Get(&list, `SELECT id, uri, IFNULL(name, '') AS name, IFNULL(description, '') AS description,
+		IFNULL(tags, '') AS tags, IFNULL(created_date, '') AS created_date, 0 AS entry_count
+		FROM lists WHERE id = ?`, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error": "Liste introuvable"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération de la liste"}`, http.StatusInternalServerError)
+		return
+	}
+
+	err = db.Select(&list.Entries, `SELECT e.list_id, e.position, IFNULL(e.entry_uri, '') AS entry_uri,
+		IFNULL(e.note, '') AS note, `+store.MovieColumns+`
+		FROM list_entries e
+		JOIN movies m ON m.letterboxd_uri = e.letterboxd_uri
+		WHERE e.list_id = ?
+		ORDER BY e.position`, id)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération des films de la liste"}`, http.StatusInternalServerError)
+		return
+	}
+	list.EntryCount = len(list.Entries)
+
+	json.NewEncoder(w).Encode(list)
+}
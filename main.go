@@ -4,56 +4,49 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
 )
 
 type Statistics struct {
 	AverageRuntime         float64       `json:"average_runtime"`
 	TopProductionCountries []CountryStat `json:"top_production_countries"`
+	// Tous les pays de production, coproductions comprises, pour la carte du monde
+	ProductionCountries []CountryStat `json:"production_countries" db:"-"`
+	// Statistiques par visionnage, calculées à partir du journal (diary.csv)
+	TotalViewings        int        `json:"total_viewings" db:"total_viewings"`
+	Rewatches            int        `json:"rewatches" db:"rewatches"`
+	AverageViewingRating float64    `json:"average_viewing_rating" db:"average_viewing_rating"`
+	TotalMinutesWatched  int        `json:"total_minutes_watched" db:"total_minutes_watched"`
+	ViewingsByYear       []YearStat `json:"viewings_by_year" db:"-"`
+	// Films aimés par rapport aux films vus et notés
+	LikesByDecade  []LikeStat `json:"likes_by_decade" db:"-"`
+	LikesByCountry []LikeStat `json:"likes_by_country" db:"-"`
+	LikesByGenre   []LikeStat `json:"likes_by_genre" db:"-"`
+	// Films vus par genre TMDB
+	Genres []GenreStat `json:"genres" db:"-"`
+	// Films vus par studio, par mot-clé (les plus fréquents) et par langue parlée
+	Studios         []BreakdownStat `json:"studios" db:"-"`
+	Keywords        []BreakdownStat `json:"keywords" db:"-"`
+	SpokenLanguages []BreakdownStat `json:"spoken_languages" db:"-"`
 }
 
-type CountryStat struct {
-	Country string `json:"country"`
-	Count   int    `json:"count"`
-}
-
-// ProductionCountry représente un pays de production issu du JSON.
-type ProductionCountry struct {
-	ISO3166_1 string `json:"iso_3166_1"`
-	Name      string `json:"name"`
-}
-
-// Movie représente la structure d'un film.
-type Movie struct {
-	LetterboxdURI            string  `json:"letterboxd_uri" db:"letterboxd_uri"` // Utilisé comme identifiant global
-	Title                    string  `json:"title" db:"title"`
-	OriginalTitle            string  `json:"original_title" db:"original_title"`
-	Overview                 string  `json:"overview" db:"overview"`
-	ReleaseDate              string  `json:"release_date" db:"release_date"`
-	PosterPath               string  `json:"poster_path" db:"poster_path"`
-	Popularity               float64 `json:"popularity" db:"popularity"`
-	VoteAverage              float64 `json:"vote_average" db:"vote_average"`
-	VoteCount                int     `json:"vote_count" db:"vote_count"`
-	Adult                    bool    `json:"adult" db:"adult"`
-	OriginalLanguage         string  `json:"original_language" db:"original_language"`
-	Runtime                  int     `json:"runtime" db:"runtime"`
-	Tagline                  string  `json:"tagline" db:"tagline"`
-	Status                   string  `json:"status" db:"status"`
-	Source                   string  `json:"source" db:"source"`
-	Year                     int     `json:"year" db:"year"`
-	MainProductionCountry    string  `json:"main_production_country" db:"main_production_country"`
-	OtherProductionCountries string  `json:"other_production_countries" db:"other_production_countries"`
-	// Champ temporaire pour l'import JSON
-	ProductionCountries []ProductionCountry `json:"production_countries" db:"-"`
+// YearStat compte les visionnages d'une année.
+type YearStat struct {
+	Year  string `json:"year" db:"year"`
+	Count int    `json:"count" db:"count"`
 }
 
 // Watched représente un film visionné
@@ -73,7 +66,8 @@ type Watchlist struct {
 // Review représente une critique de film
 type Review struct {
 	ID            int     `db:"id"`
-	LetterboxdURI string  `db:"letterboxd_uri"`
+	LetterboxdURI string  `db:"letterboxd_uri"` // Film canonique
+	EntryURI      string  `db:"entry_uri"`      // Entrée de journal de la critique
 	ReviewDate    string  `db:"review_date"`
 	Rating        float64 `db:"rating"`
 	Rewatch       bool    `db:"rewatch"`
@@ -82,6 +76,18 @@ type Review struct {
 	WatchedDate   string  `db:"watched_date"`
 }
 
+// DiaryEntry représente un visionnage consigné dans le journal
+type DiaryEntry struct {
+	ID            int     `db:"id"`
+	LetterboxdURI string  `db:"letterboxd_uri"` // Film canonique
+	EntryURI      string  `db:"entry_uri"`      // Entrée de journal
+	DiaryDate     string  `db:"diary_date"`
+	Rating        float64 `db:"rating"`
+	Rewatch       bool    `db:"rewatch"`
+	Tags          string  `db:"tags"`
+	WatchedDate   string  `db:"watched_date"`
+}
+
 // Rating représente une notation de film
 type Rating struct {
 	ID            int     `db:"id"`
@@ -93,47 +99,242 @@ type Rating struct {
 // Comment représente un commentaire sur un film
 type Comment struct {
 	ID            int    `db:"id"`
-	LetterboxdURI string `db:"letterboxd_uri"`
+	LetterboxdURI string `db:"letterboxd_uri"` // Film canonique
+	EntryURI      string `db:"entry_uri"`      // Entrée commentée
 	CommentDate   string `db:"comment_date"`
 	CommentText   string `db:"comment"`
 }
 
+// ImportStats compte, pour un fichier importé, les lignes ajoutées, mises à jour ou ignorées,
+// et les lignes supprimées car absentes du fichier.
+type ImportStats struct {
+	Added   int `json:"added" db:"added"`
+	Updated int `json:"updated" db:"updated"`
+	Skipped int `json:"skipped" db:"skipped"`
+	Removed int `json:"removed" db:"removed"`
+}
+
+// ImportRunFile représente le résultat de l'import d'un fichier lors d'un run.
+type ImportRunFile struct {
+	RunID int64  `json:"-" db:"run_id"`
+	File  string `json:"file" db:"file"`
+	ImportStats
+}
+
+// ImportRun représente une exécution de l'import d'un export, au démarrage du serveur ou
+// après un envoi sur /api/imports. Son statut passe de queued à running puis done ou failed.
+type ImportRun struct {
+	ID         int64           `json:"id" db:"id"`
+	StartedAt  string          `json:"started_at" db:"started_at"`
+	FinishedAt string          `json:"finished_at" db:"finished_at"`
+	Status     string          `json:"status" db:"status"`
+	Source     string          `json:"source" db:"source"`
+	Files      []ImportRunFile `json:"files" db:"-"`
+	Issues     []ImportIssue   `json:"issues" db:"-"`
+}
+
+// dbtx regroupe les méthodes communes à *sqlx.DB et *sqlx.Tx utilisées par l'import,
+// qui s'exécute dans une transaction.
+type dbtx interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+}
+
+// importRun porte l'état d'un run d'import en cours : la transaction dans laquelle il écrit
+// et son rapport de validation, enregistré à la fin du run.
+type importRun struct {
+	id     int64
+	tx     *sqlx.Tx
+	stmts  *stmtCache
+	issues []ImportIssue
+	films  map[string]string // URI déjà résolues pendant le run -> film canonique
+}
+
+// stmtCache implémente dbtx sur une transaction en ne préparant qu'une fois chaque requête :
+// la résolution des films exécute les mêmes requêtes pour chaque ligne importée.
+type stmtCache struct {
+	tx    *sqlx.Tx
+	stmts map[string]*sqlx.Stmt
+	named map[string]*sqlx.NamedStmt
+}
+
+func newStmtCache(tx *sqlx.Tx) *stmtCache {
+	return &stmtCache{tx: tx, stmts: make(map[string]*sqlx.Stmt), named: make(map[string]*sqlx.NamedStmt)}
+}
+
+func (c *stmtCache) prepare(query string) (*sqlx.Stmt, error) {
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := c.tx.Preparex(query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (c *stmtCache) Get(dest interface{}, query string, args ...interface{}) error {
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return err
+	}
+	return stmt.Get(dest, args...)
+}
+
+func (c *stmtCache) Select(dest interface{}, query string, args ...interface{}) error {
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return err
+	}
+	return stmt.Select(dest, args...)
+}
+
+func (c *stmtCache) Exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+func (c *stmtCache) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	stmt, ok := c.named[query]
+	if !ok {
+		var err error
+		if stmt, err = c.tx.PrepareNamed(query); err != nil {
+			return nil, err
+		}
+		c.named[query] = stmt
+	}
+	return stmt.Exec(arg)
+}
+
+// Close libère les requêtes préparées.
+func (c *stmtCache) Close() {
+	for _, stmt := range c.stmts {
+		stmt.Close()
+	}
+	for _, stmt := range c.named {
+		stmt.Close()
+	}
+}
+
+// resolveFilm résout une URI via resolveFilm en mémorisant le résultat pour le reste du run,
+// les mêmes films revenant d'un fichier à l'autre.
+func (r *importRun) resolveFilm(uri, name string, year int, source string) (string, error) {
+	if filmURI, ok := r.films[uri]; ok {
+		return filmURI, nil
+	}
+	filmURI, err := resolveFilm(r.stmts, uri, name, year, source)
+	if err != nil {
+		return "", err
+	}
+	if r.films == nil {
+		r.films = make(map[string]string)
+	}
+	r.films[uri] = filmURI
+	return filmURI, nil
+}
+
+// withSavepoint exécute fn dans un point de sauvegarde de la transaction du run : si fn
+// échoue, ses écritures sont annulées sans toucher au reste du run.
+func (r *importRun) withSavepoint(fn func() error) error {
+	if _, err := r.tx.Exec(`SAVEPOINT import_file`); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		r.tx.Exec(`ROLLBACK TO import_file`)
+		r.tx.Exec(`RELEASE import_file`)
+		// Les films créés dans le point de sauvegarde ont disparu avec lui
+		r.films = nil
+		return err
+	}
+	_, err := r.tx.Exec(`RELEASE import_file`)
+	return err
+}
+
+// importOutcome indique ce qu'un upsert a fait d'une ligne.
+type importOutcome int
+
+const (
+	outcomeAdded importOutcome = iota
+	outcomeUpdated
+	outcomeSkipped
+)
+
+func (s *ImportStats) add(o importOutcome) {
+	switch o {
+	case outcomeAdded:
+		s.Added++
+	case outcomeUpdated:
+		s.Updated++
+	default:
+		s.Skipped++
+	}
+}
+
+// csvSources liste les fichiers CSV de l'export importés au démarrage.
+// Les sources qui portent des URI de films passent en premier pour que les entrées de
+// journal (critiques, commentaires) puissent être rattachées à un film déjà connu.
+var csvSources = []string{"watched", "watchlist", "ratings", "diary", "reviews", "comments"}
+
 // db est la variable globale pour la base SQLite.
 var db *sqlx.DB
 
 func main() {
 	var err error
-	// Ouvrir (ou créer) la base SQLite avec SQLx
-	db, err = sqlx.Open("sqlite3", "./movies.db")
+	// Ouvrir (ou créer) la base SQLite, partagée avec tmdb_call.go
+	db, err = store.Open("movies.db")
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	// Création des tables avec la nouvelle structure
-	if err := createTables(db); err != nil {
+	flag.StringVar(&tmdbFile, "tmdb", "", "fichier JSON exporté par tmdb_call.go -output, à importer")
+	reportFile := flag.String("report", "", "écrit le rapport de validation de l'import au démarrage dans ce fichier JSON (- pour la sortie standard)")
+	migrationStatus := flag.Bool("migrations", false, "affiche les migrations du schéma appliquées et en attente, puis quitte")
+	flag.Parse()
+
+	if *migrationStatus {
+		if err := store.PrintMigrationStatus(db, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// Mise à jour du schéma : les migrations en attente sont appliquées sans perte de données
+	if err := store.Migrate(db); err != nil {
 		log.Fatal(err)
 	}
 
-	// Importation des fichiers CSV et JSON depuis le dossier "stats"
-	if err := importCSV(db, filepath.Join("stats", "watched.csv"), "watched"); err != nil {
-		log.Println("Erreur import CSV watched:", err)
-	}
-	if err := importCSV(db, filepath.Join("stats", "watchlist.csv"), "watchlist"); err != nil {
-		log.Println("Erreur import CSV watchlist:", err)
+	// Importation de l'export Letterboxd : l'archive ZIP d'origine ou le dossier "stats".
+	// Chaque démarrage est enregistré comme un run ; réimporter le même export ne duplique rien.
+	exportPath, err := findExportPath(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
 	}
-	if err := importCSV(db, filepath.Join("stats", "reviews.csv"), "reviews"); err != nil {
-		log.Println("Erreur import CSV reviews:", err)
+	exp, err := openExport(exportPath)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if err := importCSV(db, filepath.Join("stats", "ratings.csv"), "ratings"); err != nil {
-		log.Println("Erreur import CSV ratings:", err)
+	setExport(exp)
+	defer closeExport()
+	runID, err := startImportRun(db, exportPath)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if err := importCSV(db, filepath.Join("stats", "comments.csv"), "comments"); err != nil {
-		log.Println("Erreur import CSV comments:", err)
+	if err := runImport(db, runID, exp); err != nil {
+		log.Println("Erreur lors de l'import:", err)
 	}
-	if err := importJSON(db, filepath.Join("stats", "output.json")); err != nil {
-		log.Println("Erreur import JSON:", err)
+	if *reportFile != "" {
+		if err := writeImportReport(db, runID, *reportFile); err != nil {
+			log.Println("Erreur lors de l'écriture du rapport d'import:", err)
+		}
 	}
+	// Les exports envoyés sur /api/imports sont importés en arrière-plan, un à la fois
+	go importWorker(db)
 
 	// Servir les fichiers statiques
 	fs := http.FileServer(http.Dir("./static"))
@@ -145,83 +346,28 @@ func main() {
 	http.HandleFunc("/api/movies", moviesHandler)
 
 	http.HandleFunc("/api/statistics", statisticsHandler)
+	// Historique des imports : lignes ajoutées, mises à jour et ignorées par fichier
+	http.HandleFunc("GET /api/imports", importRunsHandler)
+	// Envoi d'un nouvel export ZIP, importé en arrière-plan, et suivi de son import
+	http.HandleFunc("POST /api/imports", createImportHandler)
+	http.HandleFunc("GET /api/imports/{id}", importRunHandler)
+	// Rapport de validation d'un import : ligne, colonne, problème et action prise
+	http.HandleFunc("GET /api/imports/{id}/report", importReportHandler)
+	// Listes Letterboxd et leurs films classés
+	http.HandleFunc("GET /api/lists", listsHandler)
+	http.HandleFunc("GET /api/lists/{id}", listHandler)
+	// Réalisateurs, chefs opérateurs, compositeurs et acteurs les plus vus
+	http.HandleFunc("GET /api/people/top", topPeopleHandler)
+	http.HandleFunc("GET /api/people/{id}/completion", completionHandler)
+	http.HandleFunc("GET /api/collections", collectionsHandler)
 
 	log.Println("Serveur démarré sur http://localhost:8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
 
-// createTables crée la table movies avec Letterboxd URI comme clé primaire et les colonnes de production.
-func createTables(db *sqlx.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS movies (
-			letterboxd_uri TEXT PRIMARY KEY,
-			title TEXT,
-			original_title TEXT,
-			overview TEXT,
-			release_date TEXT,
-			poster_path TEXT,
-			popularity REAL,
-			vote_average REAL,
-			vote_count INTEGER,
-			adult BOOLEAN,
-			original_language TEXT,
-			runtime INTEGER,
-			tagline TEXT,
-			status TEXT,
-			source TEXT,
-			year INTEGER,
-			main_production_country TEXT,
-			other_production_countries TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS watched (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			letterboxd_uri TEXT,
-			watched_date TEXT,
-			FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
-		);`,
-		`CREATE TABLE IF NOT EXISTS watchlist (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			letterboxd_uri TEXT,
-			added_date TEXT,
-			FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
-		);`,
-		`CREATE TABLE IF NOT EXISTS reviews (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			letterboxd_uri TEXT,
-			review_date TEXT,
-			rating REAL,
-			rewatch BOOLEAN,
-			review TEXT,
-			tags TEXT,
-			watched_date TEXT,
-			FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
-		);`,
-		`CREATE TABLE IF NOT EXISTS ratings (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			letterboxd_uri TEXT,
-			rating_date TEXT,
-			rating REAL,
-			FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
-		);`,
-		`CREATE TABLE IF NOT EXISTS comments (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			letterboxd_uri TEXT,
-			comment_date TEXT,
-			comment TEXT,
-			FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
-		);`,
-	}
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // getOrCreateMovie recherche un film par son Letterboxd URI et l'insère s'il n'existe pas.
 // In the getOrCreateMovie function
-func getOrCreateMovie(db *sqlx.DB, title string, year int, letterboxdURI string) error {
+func getOrCreateMovie(db dbtx, title string, year int, letterboxdURI string) error {
 	// Recherche dans la table movies
 	var exists bool
 	err := db.Get(&exists, "SELECT 1 FROM movies WHERE letterboxd_uri = ? LIMIT 1", letterboxdURI)
@@ -238,154 +384,433 @@ func getOrCreateMovie(db *sqlx.DB, title string, year int, letterboxdURI string)
 	return nil
 }
 
-// importCSV lit un fichier CSV et insère les données dans la table appropriée.
-func importCSV(db *sqlx.DB, filename, source string) error {
-	f, err := os.Open(filename)
+// upsertStmt regroupe les requêtes préparées d'upsert d'une table, réutilisées pour toutes
+// les lignes d'un fichier. L'insertion doit ignorer les conflits sur la clé naturelle
+// (ON CONFLICT DO NOTHING) ; si la ligne existait déjà, la mise à jour est appliquée et ne
+// doit modifier la ligne que si ses valeurs diffèrent. Sans requête de mise à jour, toutes
+// les colonnes font partie de la clé.
+type upsertStmt struct {
+	insert *sqlx.NamedStmt
+	update *sqlx.NamedStmt
+}
+
+// prepareUpsert prépare les requêtes d'upsert dans la transaction du run.
+func prepareUpsert(tx *sqlx.Tx, insertQuery, updateQuery string) (*upsertStmt, error) {
+	insert, err := tx.PrepareNamed(insertQuery)
 	if err != nil {
-		log.Printf("Erreur lors de l'ouverture du fichier %s: %v", filename, err)
-		return err
+		return nil, err
 	}
-	defer f.Close()
-
-	reader := csv.NewReader(f)
-	reader.TrimLeadingSpace = true
+	stmt := &upsertStmt{insert: insert}
+	if updateQuery != "" {
+		if stmt.update, err = tx.PrepareNamed(updateQuery); err != nil {
+			insert.Close()
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
 
-	records, err := reader.ReadAll()
+// exec insère ou met à jour arg et indique ce qui a été fait de la ligne.
+func (u *upsertStmt) exec(arg interface{}) (importOutcome, error) {
+	res, err := u.insert.Exec(arg)
 	if err != nil {
-		return err
+		return outcomeSkipped, err
 	}
-	if len(records) < 2 {
-		return nil // Pas de données
+	if n, _ := res.RowsAffected(); n > 0 {
+		return outcomeAdded, nil
 	}
+	if u.update == nil {
+		return outcomeSkipped, nil
+	}
+	res, err = u.update.Exec(arg)
+	if err != nil {
+		return outcomeSkipped, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return outcomeUpdated, nil
+	}
+	return outcomeSkipped, nil
+}
 
-	header := records[0]
-	colIdx := make(map[string]int)
-	for i, col := range header {
-		colIdx[col] = i
+// Close libère les requêtes préparées.
+func (u *upsertStmt) Close() {
+	u.insert.Close()
+	if u.update != nil {
+		u.update.Close()
 	}
+}
+
+// csvUpserts donne, pour chaque CSV de l'export, les requêtes d'upsert de sa table.
+var csvUpserts = map[string]struct{ insert, update string }{
+	"watched": {
+		`INSERT INTO watched (letterboxd_uri, watched_date) VALUES (:letterboxd_uri, :watched_date)
+		ON CONFLICT (letterboxd_uri, watched_date) DO NOTHING`, "",
+	},
+	"watchlist": {
+		`INSERT INTO watchlist (letterboxd_uri, added_date) VALUES (:letterboxd_uri, :added_date)
+		ON CONFLICT (letterboxd_uri, added_date) DO NOTHING`, "",
+	},
+	"diary": {
+		`INSERT INTO diary
+		(letterboxd_uri, entry_uri, diary_date, rating, rewatch, tags, watched_date)
+		VALUES (:letterboxd_uri, :entry_uri, :diary_date, :rating, :rewatch, :tags, :watched_date)
+		ON CONFLICT (entry_uri, diary_date) DO NOTHING`,
+		`UPDATE diary SET letterboxd_uri = :letterboxd_uri, rating = :rating, rewatch = :rewatch,
+			tags = :tags, watched_date = :watched_date
+		WHERE entry_uri = :entry_uri AND diary_date = :diary_date
+		AND (letterboxd_uri IS NOT :letterboxd_uri OR rating IS NOT :rating OR rewatch IS NOT :rewatch
+			OR tags IS NOT :tags OR watched_date IS NOT :watched_date)`,
+	},
+	"reviews": {
+		`INSERT INTO reviews 
+		(letterboxd_uri, entry_uri, review_date, rating, rewatch, review, tags, watched_date)
+		VALUES (:letterboxd_uri, :entry_uri, :review_date, :rating, :rewatch, :review, :tags, :watched_date)
+		ON CONFLICT (entry_uri, review_date) DO NOTHING`,
+		`UPDATE reviews SET letterboxd_uri = :letterboxd_uri, rating = :rating, rewatch = :rewatch, review = :review,
+			tags = :tags, watched_date = :watched_date
+		WHERE entry_uri = :entry_uri AND review_date = :review_date
+		AND (letterboxd_uri IS NOT :letterboxd_uri OR rating IS NOT :rating OR rewatch IS NOT :rewatch
+			OR review IS NOT :review OR tags IS NOT :tags OR watched_date IS NOT :watched_date)`,
+	},
+	// Une seule note par film : un film noté à nouveau remplace sa note et sa date
+	"ratings": {
+		`INSERT INTO ratings (letterboxd_uri, rating_date, rating) VALUES (:letterboxd_uri, :rating_date, :rating)
+		ON CONFLICT (letterboxd_uri) DO NOTHING`,
+		`UPDATE ratings SET rating_date = :rating_date, rating = :rating
+		WHERE letterboxd_uri = :letterboxd_uri AND (rating_date IS NOT :rating_date OR rating IS NOT :rating)`,
+	},
+	"comments": {
+		`INSERT INTO comments (letterboxd_uri, entry_uri, comment_date, comment)
+		VALUES (:letterboxd_uri, :entry_uri, :comment_date, :comment)
+		ON CONFLICT (entry_uri, comment_date, comment) DO NOTHING`,
+		`UPDATE comments SET letterboxd_uri = :letterboxd_uri
+		WHERE entry_uri = :entry_uri AND comment_date = :comment_date AND comment = :comment
+		AND letterboxd_uri IS NOT :letterboxd_uri`,
+	},
+}
 
-	for _, row := range records[1:] {
-		date := row[colIdx["Date"]]
-		name := row[colIdx["Name"]]
-		year, _ := strconv.Atoi(row[colIdx["Year"]])
-		letterboxdURI := row[colIdx["Letterboxd URI"]]
+// csvSnapshots donne, pour chaque CSV de l'export, la requête qui lit l'identifiant et la
+// clé naturelle (voir snapshotKey) de chaque ligne de sa table. Un export décrit l'état du
+// compte à sa date : une entrée supprimée sur Letterboxd (film retiré de la watchlist, note
+// effacée, entrée de journal, critique ou commentaire supprimés) n'est plus dans l'export
+// suivant, et sa ligne est supprimée à l'import.
+var csvSnapshots = map[string]string{
+	"watched":   `SELECT id, IFNULL(letterboxd_uri, '') || ' ' || IFNULL(watched_date, '') AS key FROM watched`,
+	"watchlist": `SELECT id, IFNULL(letterboxd_uri, '') || ' ' || IFNULL(added_date, '') AS key FROM watchlist`,
+	"ratings":   `SELECT id, IFNULL(letterboxd_uri, '') AS key FROM ratings`,
+	"diary":     `SELECT id, IFNULL(entry_uri, '') || ' ' || IFNULL(diary_date, '') AS key FROM diary`,
+	"reviews":   `SELECT id, IFNULL(entry_uri, '') || ' ' || IFNULL(review_date, '') AS key FROM reviews`,
+	"comments": `SELECT id, IFNULL(entry_uri, '') || ' ' || IFNULL(comment_date, '') || ' ' || IFNULL(comment, '') AS key
+		FROM comments`,
+}
 
-		if err := getOrCreateMovie(db, name, year, letterboxdURI); err != nil {
-			log.Printf("Erreur lors de la récupération/création du film %s: %v", name, err)
+// snapshotKey renvoie la clé naturelle d'une ligne d'un CSV, telle que la lit csvSnapshots.
+func snapshotKey(arg interface{}) string {
+	switch a := arg.(type) {
+	case Watched:
+		return a.LetterboxdURI + " " + a.WatchedDate
+	case Watchlist:
+		return a.LetterboxdURI + " " + a.AddedDate
+	case Rating:
+		return a.LetterboxdURI
+	case DiaryEntry:
+		return a.EntryURI + " " + a.DiaryDate
+	case Review:
+		return a.EntryURI + " " + a.ReviewDate
+	case Comment:
+		return a.EntryURI + " " + a.CommentDate + " " + a.CommentText
+	}
+	return ""
+}
+
+// removeMissing supprime de table les lignes lues par query (colonnes id et key) dont la clé
+// n'est pas dans seen, c'est-à-dire absentes du fichier importé, et renvoie leur nombre.
+func removeMissing(run *importRun, table, query string, seen map[string]bool, args ...interface{}) (int, error) {
+	var rows []struct {
+		ID  int64  `db:"id"`
+		Key string `db:"key"`
+	}
+	if err := run.tx.Select(&rows, query, args...); err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, row := range rows {
+		if seen[row.Key] {
 			continue
 		}
+		if _, err := run.stmts.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, table), row.ID); err != nil {
+			return removed, fmt.Errorf("suppression dans %s: %w", table, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// csvColumns liste, pour chaque CSV de l'export, les colonnes sans lesquelles ses lignes ne
+// peuvent pas être importées. Elles sont vérifiées avant l'écriture de la première ligne.
+var csvColumns = map[string][]string{
+	"watched":   {"Date", "Name", "Year", "Letterboxd URI"},
+	"watchlist": {"Date", "Name", "Year", "Letterboxd URI"},
+	"diary":     {"Date", "Name", "Year", "Letterboxd URI", "Rating", "Rewatch", "Tags"},
+	"reviews":   {"Date", "Name", "Year", "Letterboxd URI", "Rating", "Rewatch", "Review", "Tags"},
+	"ratings":   {"Date", "Name", "Year", "Letterboxd URI", "Rating"},
+	"comments":  {"Date", "Name", "Year", "Letterboxd URI", "Comment"},
+}
+
+// importCSV lit un CSV de l'export ligne à ligne et insère ou met à jour les données dans la
+// table appropriée. Le fichier est importé dans un point de sauvegarde de la transaction du
+// run : en cas d'erreur, aucune de ses lignes n'est conservée. Réimporter le même fichier ne
+// crée aucun doublon : les lignes déjà présentes sont ignorées. Les lignes et valeurs
+// invalides sont reportées dans le rapport du run. Les lignes absentes du fichier sont
+// supprimées (voir csvSnapshots).
+func importCSV(run *importRun, r io.Reader, name, source string) (ImportStats, error) {
+	var stats ImportStats
+	queries, ok := csvUpserts[source]
+	if !ok {
+		return stats, fmt.Errorf("source inconnue: %s", source)
+	}
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return stats, nil // Pas de données
+	}
+	if err != nil {
+		return stats, err
+	}
+	colIdx := columnIndex(header)
+	if missing := missingColumns(colIdx, csvColumns[source]...); len(missing) > 0 {
+		return stats, &missingColumnsError{line: 1, columns: missing}
+	}
+	fieldCount := len(header) // header est réutilisé par les lectures suivantes
 
-		switch source {
-		case "watched":
-			watched := Watched{
-				LetterboxdURI: letterboxdURI,
-				WatchedDate:   date,
+	// Clés des lignes du fichier, et nombre de lignes rejetées, pour supprimer celles qui n'y
+	// figurent plus
+	seen := make(map[string]bool)
+	rejected := 0
+
+	err = run.withSavepoint(func() error {
+		stmt, err := prepareUpsert(run.tx, queries.insert, queries.update)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
 			}
-			_, err = db.NamedExec("INSERT INTO watched (letterboxd_uri, watched_date) VALUES (:letterboxd_uri, :watched_date)", watched)
-		case "watchlist":
-			watchlist := Watchlist{
-				LetterboxdURI: letterboxdURI,
-				AddedDate:     date,
+			var parseErr *csv.ParseError
+			if errors.Is(err, csv.ErrFieldCount) && errors.As(err, &parseErr) {
+				run.report(ImportIssue{File: name, Row: parseErr.StartLine, Problem: problemFieldCount, Action: actionRowSkipped,
+					Message: fmt.Sprintf("%d champs au lieu de %d", len(record), fieldCount)})
+				rejected++
+				continue
 			}
-			_, err = db.NamedExec("INSERT INTO watchlist (letterboxd_uri, added_date) VALUES (:letterboxd_uri, :added_date)", watchlist)
-		case "reviews":
-			rating, _ := strconv.ParseFloat(row[colIdx["Rating"]], 64)
-			rewatch := false
-			if val := row[colIdx["Rewatch"]]; strings.TrimSpace(val) != "" {
-				rewatch = true
+			if err != nil {
+				return err
 			}
-			reviewText := row[colIdx["Review"]]
-			tags := row[colIdx["Tags"]]
-			watchedDate := ""
-			if idx, ok := colIdx["Watched Date"]; ok {
-				watchedDate = row[idx]
+			line, _ := reader.FieldPos(0)
+			row := csvRow{run: run, file: name, line: line, colIdx: colIdx, fields: record}
+
+			entryURI := row.get("Letterboxd URI")
+			if entryURI == "" {
+				row.issue("Letterboxd URI", problemMissingValue, actionRowSkipped, "URI Letterboxd vide")
+				rejected++
+				continue
 			}
+			date, ok := row.date("Date")
+			if !ok {
+				row.issue("Date", problemInvalidDate, actionRowSkipped, "date %q invalide (attendu : AAAA-MM-JJ)", date)
+				rejected++
+				continue
+			}
+			title := row.get("Name")
+			year := row.year()
 
-			review := Review{
-				LetterboxdURI: letterboxdURI,
-				ReviewDate:    date,
-				Rating:        rating,
-				Rewatch:       rewatch,
-				ReviewText:    reviewText,
-				Tags:          tags,
-				WatchedDate:   watchedDate,
+			letterboxdURI, err := run.resolveFilm(entryURI, title, year, source)
+			if err != nil {
+				return &importError{row: line, problem: problemDatabaseError, err: fmt.Errorf("film %s: %w", title, err)}
 			}
-			_, err = db.NamedExec(`INSERT INTO reviews 
-				(letterboxd_uri, review_date, rating, rewatch, review, tags, watched_date)
-				VALUES (:letterboxd_uri, :review_date, :rating, :rewatch, :review, :tags, :watched_date)`, review)
-		case "ratings":
-			rating, _ := strconv.ParseFloat(row[colIdx["Rating"]], 64)
-			ratingObj := Rating{
-				LetterboxdURI: letterboxdURI,
-				RatingDate:    date,
-				Rating:        rating,
+
+			// La date de visionnage est facultative : elle vaut par défaut la date de l'entrée
+			// dans le journal, et reste vide pour une critique
+			watchedDate := ""
+			if source == "diary" {
+				watchedDate = date
 			}
-			_, err = db.NamedExec("INSERT INTO ratings (letterboxd_uri, rating_date, rating) VALUES (:letterboxd_uri, :rating_date, :rating)", ratingObj)
-		case "comments":
-			commentText := row[colIdx["Comment"]]
-			comment := Comment{
-				LetterboxdURI: letterboxdURI,
-				CommentDate:   date,
-				CommentText:   commentText,
+			if v, ok := row.date("Watched Date"); ok {
+				watchedDate = v
+			} else if v != "" {
+				row.issue("Watched Date", problemInvalidDate, actionValueDefaulted,
+					"date de visionnage %q invalide, remplacée par %q", v, watchedDate)
 			}
-			_, err = db.NamedExec("INSERT INTO comments (letterboxd_uri, comment_date, comment) VALUES (:letterboxd_uri, :comment_date, :comment)", comment)
-		default:
-			log.Printf("Source inconnue: %s", source)
+
+			var arg interface{}
+			switch source {
+			case "watched":
+				arg = Watched{
+					LetterboxdURI: letterboxdURI,
+					WatchedDate:   date,
+				}
+			case "watchlist":
+				arg = Watchlist{
+					LetterboxdURI: letterboxdURI,
+					AddedDate:     date,
+				}
+			case "diary":
+				arg = DiaryEntry{
+					LetterboxdURI: letterboxdURI,
+					EntryURI:      entryURI,
+					DiaryDate:     date,
+					Rating:        row.rating(),
+					Rewatch:       strings.TrimSpace(row.get("Rewatch")) != "",
+					Tags:          row.get("Tags"),
+					WatchedDate:   watchedDate,
+				}
+			case "reviews":
+				arg = Review{
+					LetterboxdURI: letterboxdURI,
+					EntryURI:      entryURI,
+					ReviewDate:    date,
+					Rating:        row.rating(),
+					Rewatch:       strings.TrimSpace(row.get("Rewatch")) != "",
+					ReviewText:    row.get("Review"),
+					Tags:          row.get("Tags"),
+					WatchedDate:   watchedDate,
+				}
+			case "ratings":
+				arg = Rating{
+					LetterboxdURI: letterboxdURI,
+					RatingDate:    date,
+					Rating:        row.rating(),
+				}
+			case "comments":
+				arg = Comment{
+					LetterboxdURI: letterboxdURI,
+					EntryURI:      entryURI,
+					CommentDate:   date,
+					CommentText:   row.get("Comment"),
+				}
+			}
+
+			outcome, err := stmt.exec(arg)
+			if err != nil {
+				return &importError{row: line, problem: problemDatabaseError, err: fmt.Errorf("insertion dans %s: %w", source, err)}
+			}
+			stats.add(outcome)
+			seen[snapshotKey(arg)] = true
 		}
 
-		if err != nil {
-			log.Printf("Erreur lors de l'insertion dans %s: %v", source, err)
+		// Une ligne rejetée peut être celle d'une ligne en base : rien n'est supprimé
+		if rejected > 0 {
+			log.Printf("%s: %d ligne(s) rejetée(s), les lignes absentes du fichier sont conservées", name, rejected)
+			return nil
 		}
+		stats.Removed, err = removeMissing(run, source, csvSnapshots[source], seen)
+		return err
+	})
+	if err != nil {
+		return ImportStats{}, err
 	}
-	return nil
+	return stats, nil
 }
 
-// importJSON lit le fichier JSON et insère ou met à jour les films dans la base.
-func importJSON(db *sqlx.DB, filename string) error {
-	f, err := os.Open(filename)
-	if err != nil {
-		log.Printf("Erreur lors de l'ouverture du fichier %s: %v", filename, err)
-		return err
-	}
-	defer f.Close()
+// importJSON lit le JSON exporté par tmdb_call.go -output (ou produit par ses anciennes
+// versions) et insère ou met à jour les films dans la base.
+func importJSON(run *importRun, r io.Reader, name string) (ImportStats, error) {
+	var stats ImportStats
 
-	var movies []Movie
-	decoder := json.NewDecoder(f)
-	if err := decoder.Decode(&movies); err != nil {
-		return err
+	// Dans le JSON, l'identifiant TMDB s'appelle id
+	var entries []struct {
+		store.Movie
+		ID int `json:"id"`
+	}
+	decoder := json.NewDecoder(r)
+	if err := decoder.Decode(&entries); err != nil {
+		return stats, err
 	}
 
-	for _, m := range movies {
-		// Extraire le pays principal et les autres pays à partir du tableau ProductionCountries
-		var mainCountry string
-		var otherCountries []string
-		for i, pc := range m.ProductionCountries {
-			if i == 0 {
-				mainCountry = pc.Name
-			} else {
-				otherCountries = append(otherCountries, pc.Name)
-			}
+	for i, e := range entries {
+		m := e.Movie
+		if e.ID != 0 {
+			m.TMDBID = e.ID
 		}
-		m.MainProductionCountry = mainCountry
-		m.OtherProductionCountries = strings.Join(otherCountries, ", ")
-
-		// Insertion ou mise à jour du film dans la table movies avec NamedExec
-		_, err := db.NamedExec(`INSERT OR REPLACE INTO movies 
-			(letterboxd_uri, title, original_title, overview, release_date, poster_path, 
-			popularity, vote_average, vote_count, adult, original_language, runtime, 
-			tagline, status, source, year, main_production_country, other_production_countries)
-			VALUES (:letterboxd_uri, :title, :original_title, :overview, :release_date, :poster_path, 
-			:popularity, :vote_average, :vote_count, :adult, :original_language, :runtime, 
-			:tagline, :status, :source, :year, :main_production_country, :other_production_countries)`, m)
+		res, err := store.SaveMovie(run.stmts, m)
 		if err != nil {
-			log.Printf("Erreur lors de l'insertion/mise à jour du film %s: %v", m.Title, err)
+			run.report(jsonIssue(name, i, "film %s: %v", m.Title, err))
 			continue
 		}
+		if res.TMDBConflict != "" {
+			run.report(ImportIssue{File: name, Row: i + 1, Column: "tmdb_id", Problem: problemDuplicateID,
+				Action: actionValueDefaulted, Message: fmt.Sprintf("identifiant TMDB %d de %s déjà attribué à %s, ignoré",
+					m.TMDBID, m.Title, res.TMDBConflict)})
+		}
+		if res.IMDbConflict != "" {
+			run.report(ImportIssue{File: name, Row: i + 1, Column: "imdb_id", Problem: problemDuplicateID,
+				Action: actionValueDefaulted, Message: fmt.Sprintf("identifiant IMDb %s de %s déjà attribué à %s, ignoré",
+					m.IMDbID, m.Title, res.IMDbConflict)})
+		}
+		switch {
+		case res.Added:
+			stats.add(outcomeAdded)
+		case res.Changed:
+			stats.add(outcomeUpdated)
+		default:
+			stats.add(outcomeSkipped)
+		}
+	}
+	return stats, nil
+}
+
+// jsonIssue décrit l'échec de l'écriture du i-ème film du JSON, qui n'est pas importé.
+func jsonIssue(name string, i int, format string, args ...interface{}) ImportIssue {
+	return ImportIssue{File: name, Row: i + 1, Problem: problemDatabaseError, Action: actionRowSkipped,
+		Message: fmt.Sprintf(format, args...)}
+}
+
+// startImportRun enregistre un run d'import en attente et renvoie son identifiant.
+func startImportRun(db *sqlx.DB, source string) (int64, error) {
+	res, err := db.Exec(`INSERT INTO import_runs (started_at, status, source) VALUES (?, 'queued', ?)`,
+		time.Now().Format(time.RFC3339), source)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// recordImportFile enregistre, dans la transaction du run, les compteurs d'un fichier importé.
+func recordImportFile(run *importRun, file string, stats ImportStats) {
+	log.Printf("Import %s: %d ajoutés, %d mis à jour, %d ignorés, %d supprimés", file, stats.Added, stats.Updated,
+		stats.Skipped, stats.Removed)
+	_, err := run.tx.NamedExec(`INSERT OR REPLACE INTO import_run_files (run_id, file, added, updated, skipped, removed)
+		VALUES (:run_id, :file, :added, :updated, :skipped, :removed)`, ImportRunFile{RunID: run.id, File: file, ImportStats: stats})
+	if err != nil {
+		run.report(ImportIssue{File: file, Problem: problemDatabaseError, Action: actionNone,
+			Message: fmt.Sprintf("compteurs du fichier non enregistrés: %v", err)})
+	}
+}
+
+// finishImportRun clôture un run d'import avec le statut donné et enregistre son rapport.
+func finishImportRun(db *sqlx.DB, run *importRun, status string) error {
+	if _, err := db.Exec(`UPDATE import_runs SET finished_at = ?, status = ? WHERE id = ?`,
+		time.Now().Format(time.RFC3339), status, run.id); err != nil {
+		return err
+	}
+	for _, issue := range run.issues {
+		if _, err := db.NamedExec(`INSERT INTO import_run_errors (run_id, file, line, column_name, problem, action, message)
+			VALUES (:run_id, :file, :line, :column_name, :problem, :action, :message)`, issue); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// dataHandler reste inchangé et lit un fichier CSV dans le dossier "stats".
+// dataHandler lit un fichier CSV de l'export courant, dossier ou archive ZIP.
 func dataHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
@@ -397,6 +822,7 @@ func dataHandler(w http.ResponseWriter, r *http.Request) {
 		"reviews":   true,
 		"ratings":   true,
 		"comments":  true,
+		"diary":     true,
 	}
 
 	if !validTypes[fileType] {
@@ -404,8 +830,9 @@ func dataHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	path := filepath.Join("stats", fileType+".csv")
-	file, err := os.Open(path)
+	exportMu.RLock()
+	defer exportMu.RUnlock()
+	file, err := export.Open(fileType + ".csv")
 	if err != nil {
 		http.Error(w, `{"error": "Fichier non trouvé"}`, http.StatusNotFound)
 		return
@@ -437,12 +864,36 @@ func moviesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
-	var movies []Movie
-	err := db.Select(&movies, `SELECT 
-		letterboxd_uri, title, original_title, overview, release_date, poster_path, 
-		popularity, vote_average, vote_count, adult, original_language, runtime, 
-		tagline, status, source, year, main_production_country, other_production_countries 
-		FROM movies`)
+	// Filtres facultatifs par identifiant TMDB ou IMDb, pour recouper avec d'autres outils
+	var where []string
+	var args []interface{}
+	query := r.URL.Query()
+	if v := query.Get("tmdb_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, `{"error": "Paramètre tmdb_id invalide"}`, http.StatusBadRequest)
+			return
+		}
+		where = append(where, "m.tmdb_id = ?")
+		args = append(args, id)
+	}
+	if v := query.Get("imdb_id"); v != "" {
+		where = append(where, "m.imdb_id = ?")
+		args = append(args, v)
+	}
+	filter := ""
+	if len(where) > 0 {
+		filter = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	// liked indique si le film fait partie des films aimés (likes/films.csv)
+	movies := []struct {
+		store.Movie
+		Liked bool `json:"liked" db:"liked"`
+	}{}
+	err := db.Select(&movies, `SELECT `+store.MovieColumns+`,
+		EXISTS (SELECT 1 FROM likes l WHERE l.kind = 'film' AND l.letterboxd_uri = m.letterboxd_uri) AS liked
+		FROM movies m`+filter, args...)
 	if err != nil {
 		http.Error(w, `{"error": "Erreur lors de la récupération des films"}`, http.StatusInternalServerError)
 		return
@@ -460,7 +911,7 @@ func statisticsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get average runtime of watched movies
 	err := db.Get(&stats.AverageRuntime, `
-        SELECT AVG(m.runtime) 
+        SELECT IFNULL(AVG(m.runtime), 0)
         FROM movies m
         JOIN watched w ON m.letterboxd_uri = w.letterboxd_uri
         WHERE m.runtime > 0
@@ -470,22 +921,117 @@ func statisticsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get top 3 production countries
-	var countries []CountryStat
-	err = db.Select(&countries, `
-        SELECT main_production_country as country, COUNT(*) as count
-        FROM movies
-        WHERE main_production_country != ""
-        GROUP BY main_production_country
-        ORDER BY count DESC
-        LIMIT 3
-    `)
+	// Pays de production : une coproduction compte pour chacun de ses pays
+	stats.ProductionCountries, err = countryStats()
 	if err != nil {
 		http.Error(w, fmt.Sprintf(`{"error": "Erreur lors de la récupération des pays: %s"}`, err), http.StatusInternalServerError)
 		return
 	}
+	stats.TopProductionCountries = stats.ProductionCountries[:min(3, len(stats.ProductionCountries))]
+
+	// Statistiques par visionnage : un film revu compte autant de fois qu'il a été vu
+	err = db.Get(&stats, `
+        SELECT COUNT(*) AS total_viewings,
+            IFNULL(SUM(d.rewatch), 0) AS rewatches,
+            IFNULL(AVG(NULLIF(d.rating, 0)), 0) AS average_viewing_rating,
+            IFNULL(SUM(m.runtime), 0) AS total_minutes_watched
+        FROM diary d
+        LEFT JOIN movies m ON m.letterboxd_uri = d.letterboxd_uri
+    `)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Erreur lors du calcul des visionnages: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.Select(&stats.ViewingsByYear, `
+        SELECT substr(watched_date, 1, 4) AS year, COUNT(*) AS count
+        FROM diary
+        WHERE watched_date != ""
+        GROUP BY year
+        ORDER BY year
+    `)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Erreur lors du calcul des visionnages par année: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
 
-	stats.TopProductionCountries = countries
+	// Ce qu'on aime par rapport à ce qu'on note bien, par décennie, par pays et par genre
+	if stats.LikesByDecade, err = likeStats("", `CASE WHEN m.year > 0 THEN ((m.year / 10) * 10) || 's' ELSE '' END`); err == nil {
+		if stats.LikesByCountry, err = likeStats(`JOIN movie_countries mc ON mc.letterboxd_uri = m.letterboxd_uri`, `mc.iso_3166_1`); err == nil {
+			stats.LikesByGenre, err = likeStats(`JOIN movie_genres mg ON mg.letterboxd_uri = m.letterboxd_uri
+				JOIN genres g ON g.id = mg.genre_id`, `g.name`)
+		}
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Erreur lors du calcul des films aimés: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if stats.Genres, err = genreStats(); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Erreur lors du calcul des genres: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
+
+	if stats.Studios, err = breakdownStats(`JOIN movie_companies mc ON mc.letterboxd_uri = w.letterboxd_uri
+		JOIN companies c ON c.id = mc.company_id`, `c.id`, `c.name`, maxBreakdownStats); err == nil {
+		if stats.Keywords, err = breakdownStats(`JOIN movie_keywords mk ON mk.letterboxd_uri = w.letterboxd_uri
+			JOIN keywords k ON k.id = mk.keyword_id`, `k.id`, `k.name`, maxBreakdownStats); err == nil {
+			stats.SpokenLanguages, err = breakdownStats(`JOIN movie_spoken_languages ml ON ml.letterboxd_uri = w.letterboxd_uri
+				JOIN languages l ON l.iso_639_1 = ml.iso_639_1`, `l.iso_639_1`, `IFNULL(NULLIF(l.english_name, ''), l.iso_639_1)`, 0)
+		}
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": "Erreur lors du calcul des studios, mots-clés et langues: %s"}`, err), http.StatusInternalServerError)
+		return
+	}
 
 	json.NewEncoder(w).Encode(stats)
 }
+
+// importRunsHandler renvoie l'historique des runs d'import avec les compteurs par fichier.
+func importRunsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	runs := []ImportRun{}
+	err := db.Select(&runs, `SELECT id, IFNULL(started_at, '') AS started_at,
+		IFNULL(finished_at, '') AS finished_at, IFNULL(status, '') AS status, IFNULL(source, '') AS source
+		FROM import_runs ORDER BY id DESC`)
+	if err == nil {
+		err = loadImportDetails(runs)
+	}
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération des imports"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(runs)
+}
+
+// loadImportDetails complète les runs avec leurs compteurs par fichier et leur rapport.
+func loadImportDetails(runs []ImportRun) error {
+	var files []ImportRunFile
+	if err := db.Select(&files, `SELECT run_id, file, added, updated, skipped, removed FROM import_run_files ORDER BY file`); err != nil {
+		return err
+	}
+	var issues []ImportIssue
+	if err := db.Select(&issues, `SELECT run_id, IFNULL(file, '') AS file, IFNULL(line, 0) AS line,
+		IFNULL(column_name, '') AS column_name, IFNULL(problem, '') AS problem, IFNULL(action, '') AS action,
+		IFNULL(message, '') AS message
+		FROM import_run_errors ORDER BY id`); err != nil {
+		return err
+	}
+	filesByRun := make(map[int64][]ImportRunFile)
+	for _, f := range files {
+		filesByRun[f.RunID] = append(filesByRun[f.RunID], f)
+	}
+	issuesByRun := make(map[int64][]ImportIssue)
+	for _, issue := range issues {
+		issuesByRun[issue.RunID] = append(issuesByRun[issue.RunID], issue)
+	}
+	for i := range runs {
+		runs[i].Files = filesByRun[runs[i].ID]
+		runs[i].Issues = issuesByRun[runs[i].ID]
+	}
+	return nil
+}
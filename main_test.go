@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+)
+
+// benchRows est le nombre de lignes de l'export synthétique, pour moitié dans watched.csv
+// et pour moitié dans diary.csv.
+const benchRows = 100_000
+
+// syntheticExport génère un export de n films vus, chacun avec une entrée de journal dont
+// l'URI doit être résolue vers le film.
+func syntheticExport(n int) fstest.MapFS {
+	var watched, diary bytes.Buffer
+	watched.WriteString("Date,Name,Year,Letterboxd URI\n")
+	diary.WriteString("Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date\n")
+	for i := 0; i < n; i++ {
+		name, year := fmt.Sprintf("Film %d", i), 1950+i%70
+		date := fmt.Sprintf("20%02d-%02d-%02d", 10+i%14, 1+i%12, 1+i%28)
+		fmt.Fprintf(&watched, "%s,%s,%d,https://boxd.it/f%d\n", date, name, year, i)
+		fmt.Fprintf(&diary, "%s,%s,%d,https://letterboxd.com/me/film/film-%d/,%.1f,,,%s\n",
+			date, name, year, i, float64(1+i%10)/2, date)
+	}
+	return fstest.MapFS{
+		"watched.csv": {Data: watched.Bytes()},
+		"diary.csv":   {Data: diary.Bytes()},
+	}
+}
+
+// openTestDB crée une base vide, au schéma à jour, dans un dossier temporaire.
+func openTestDB(tb testing.TB) *sqlx.DB {
+	db, err := store.Open(filepath.Join(tb.TempDir(), "movies.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	if err := store.Migrate(db); err != nil {
+		tb.Fatal(err)
+	}
+	return db
+}
+
+// discardLog fait taire le journal de l'import le temps d'un test.
+func discardLog(tb testing.TB) {
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(output) })
+}
+
+// importTestExport importe l'export comme au démarrage du serveur : runImport ouvre la
+// transaction du run et son cache de requêtes préparées, puis importCSV lit chaque fichier.
+// Il renvoie l'identifiant du run.
+func importTestExport(tb testing.TB, db *sqlx.DB, exp *letterboxdExport) int64 {
+	runID, err := startImportRun(db, exp.path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := runImport(db, runID, exp); err != nil {
+		tb.Fatal(err)
+	}
+	return runID
+}
+
+// importAutocommit importe l'export comme avant le passage aux transactions, pour
+// comparaison : pour chaque ligne, résolution du film et insertion hors transaction, avec
+// des requêtes préparées à chaque exécution.
+func importAutocommit(b *testing.B, db *sqlx.DB, fsys fstest.MapFS) {
+	for _, source := range []string{"watched", "diary"} {
+		f, err := fsys.Open(source + ".csv")
+		if err != nil {
+			b.Fatal(err)
+		}
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, r := range records[1:] {
+			year, _ := strconv.Atoi(r[2])
+			uri, err := resolveFilm(db, r[3], r[1], year, source)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var arg interface{} = Watched{LetterboxdURI: uri, WatchedDate: r[0]}
+			if source == "diary" {
+				rating, _ := strconv.ParseFloat(r[4], 64)
+				arg = DiaryEntry{LetterboxdURI: uri, EntryURI: r[3], DiaryDate: r[0], Rating: rating, WatchedDate: r[7]}
+			}
+			if _, err := db.NamedExec(csvUpserts[source].insert, arg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkImportCSV mesure l'import d'un export synthétique de 100 000 lignes, dans une
+// base vide (first) puis déjà à jour (reimport, où toutes les lignes sont ignorées), et
+// l'import ligne à ligne hors transaction qu'il remplace (autocommit).
+func BenchmarkImportCSV(b *testing.B) {
+	discardLog(b)
+
+	exp, err := newExport("synthetic", syntheticExport(benchRows/2), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("first", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			db := openTestDB(b)
+			b.StartTimer()
+			importTestExport(b, db, exp)
+		}
+		b.ReportMetric(float64(benchRows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+
+	b.Run("autocommit", func(b *testing.B) {
+		fsys := syntheticExport(benchRows / 2)
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			db := openTestDB(b)
+			b.StartTimer()
+			importAutocommit(b, db, fsys)
+		}
+		b.ReportMetric(float64(benchRows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+
+	b.Run("reimport", func(b *testing.B) {
+		db := openTestDB(b)
+		importTestExport(b, db, exp)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			importTestExport(b, db, exp)
+		}
+		b.ReportMetric(float64(benchRows)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+	})
+}
+
+// testExport construit un export à partir du contenu de ses fichiers.
+func testExport(tb testing.TB, files map[string]string) *letterboxdExport {
+	fsys := fstest.MapFS{}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	exp, err := newExport("test", fsys, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return exp
+}
+
+// testFilm est un film des exports de test, avec son entrée de journal.
+type testFilm struct {
+	name, year, uri, entry string
+}
+
+var (
+	alien  = testFilm{"Alien", "1979", "https://boxd.it/2b0k", "https://letterboxd.com/me/film/alien/"}
+	brazil = testFilm{"Brazil", "1985", "https://boxd.it/29Nu", "https://letterboxd.com/me/film/brazil/"}
+)
+
+// accountExport génère l'export d'un compte où chaque film est vu, dans la watchlist, noté,
+// dans le journal, critiqué, commenté, aimé et dans sa propre liste. extra est ajouté à la
+// fin de chaque fichier, une ligne invalide par exemple.
+func accountExport(tb testing.TB, films []testFilm, extra map[string]string) *letterboxdExport {
+	files := map[string]string{
+		"watched.csv":     "Date,Name,Year,Letterboxd URI\n",
+		"watchlist.csv":   "Date,Name,Year,Letterboxd URI\n",
+		"ratings.csv":     "Date,Name,Year,Letterboxd URI,Rating\n",
+		"diary.csv":       "Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date\n",
+		"reviews.csv":     "Date,Name,Year,Letterboxd URI,Rating,Rewatch,Review,Tags,Watched Date\n",
+		"comments.csv":    "Date,Name,Year,Letterboxd URI,Comment\n",
+		"likes/films.csv": "Date,Name,Year,Letterboxd URI\n",
+	}
+	for _, f := range films {
+		files["watched.csv"] += fmt.Sprintf("2024-01-02,%s,%s,%s\n", f.name, f.year, f.uri)
+		files["watchlist.csv"] += fmt.Sprintf("2023-05-06,%s,%s,%s\n", f.name, f.year, f.uri)
+		files["ratings.csv"] += fmt.Sprintf("2024-01-02,%s,%s,%s,4\n", f.name, f.year, f.uri)
+		files["diary.csv"] += fmt.Sprintf("2024-01-02,%s,%s,%s,4,,,2024-01-01\n", f.name, f.year, f.entry)
+		files["reviews.csv"] += fmt.Sprintf("2024-01-02,%s,%s,%s,4,,Great,,2024-01-01\n", f.name, f.year, f.entry)
+		files["comments.csv"] += fmt.Sprintf("2024-01-03,%s,%s,%s,Indeed\n", f.name, f.year, f.entry)
+		files["likes/films.csv"] += fmt.Sprintf("2024-01-02,%s,%s,%s\n", f.name, f.year, f.uri)
+		files["lists/"+f.name+".csv"] = fmt.Sprintf("Letterboxd list export v7\nDate,Name,Tags,URL,Description\n"+
+			"2024-01-01,%s,,https://letterboxd.com/me/list/%s/,\n\nPosition,Name,Year,URL,Description\n1,%s,%s,%s,\n",
+			f.name, f.name, f.name, f.year, f.uri)
+	}
+	for name, data := range extra {
+		files[name] += data
+	}
+	return testExport(tb, files)
+}
+
+// countRows compte les lignes de chaque table de l'état du compte.
+func countRows(tb testing.TB, db *sqlx.DB) map[string]int {
+	counts := make(map[string]int)
+	for _, table := range []string{"watched", "watchlist", "ratings", "diary", "reviews", "comments", "likes",
+		"lists", "list_entries"} {
+		var n int
+		if err := db.Get(&n, `SELECT COUNT(*) FROM `+table); err != nil {
+			tb.Fatal(err)
+		}
+		counts[table] = n
+	}
+	return counts
+}
+
+func TestReimportRemovesDeletedRows(t *testing.T) {
+	discardLog(t)
+	all := func(n int) map[string]int {
+		return map[string]int{"watched": n, "watchlist": n, "ratings": n, "diary": n, "reviews": n,
+			"comments": n, "likes": n, "lists": n, "list_entries": n}
+	}
+	tests := []struct {
+		name  string
+		extra map[string]string // Ajouté aux fichiers du second export
+		want  map[string]int
+	}{
+		{"deleted on Letterboxd", nil, all(1)},
+		{"rejected rows", map[string]string{
+			"watched.csv":      "yesterday,Brazil,1985,https://boxd.it/29Nu\n",
+			"watchlist.csv":    "2023-05-06,Brazil,1985,\n",
+			"ratings.csv":      "2024-01-02,Brazil,1985\n",
+			"diary.csv":        "yesterday,Brazil,1985,https://letterboxd.com/me/film/brazil/,4,,,\n",
+			"reviews.csv":      "2024-01-02,Brazil,1985,,4,,Great,,\n",
+			"comments.csv":     "yesterday,Brazil,1985,https://letterboxd.com/me/film/brazil/,Indeed\n",
+			"likes/films.csv":  "2024-01-02,Brazil,1985,\n",
+			"lists/Broken.csv": "Letterboxd list export v7\n",
+		}, all(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			importTestExport(t, db, accountExport(t, []testFilm{alien, brazil}, nil))
+			if got := countRows(t, db); !maps.Equal(got, all(2)) {
+				t.Fatalf("after the first import: %v", got)
+			}
+
+			importTestExport(t, db, accountExport(t, []testFilm{alien}, tt.extra))
+			if got := countRows(t, db); !maps.Equal(got, tt.want) {
+				t.Errorf("after the second import: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// useTestDB fait servir db aux handlers le temps d'un test.
+func useTestDB(t *testing.T, testDB *sqlx.DB) {
+	previous := db
+	db = testDB
+	t.Cleanup(func() { db = previous })
+}
+
+func TestStatisticsWithoutTMDB(t *testing.T) {
+	discardLog(t)
+	tests := []struct {
+		name         string
+		films        []testFilm // Films de l'export importé, sans données TMDB
+		wantViewings int
+		wantRating   float64
+	}{
+		{"empty database", nil, 0, 0},
+		{"CSV only", []testFilm{alien, brazil}, 2, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := openTestDB(t)
+			useTestDB(t, testDB)
+			if tt.films != nil {
+				importTestExport(t, testDB, accountExport(t, tt.films, nil))
+			}
+
+			rec := httptest.NewRecorder()
+			statisticsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/statistics", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var stats Statistics
+			if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil {
+				t.Fatal(err)
+			}
+			if stats.AverageRuntime != 0 || stats.TotalViewings != tt.wantViewings || stats.AverageViewingRating != tt.wantRating {
+				t.Errorf("average_runtime %v, total_viewings %d, average_viewing_rating %v; want 0, %d, %v",
+					stats.AverageRuntime, stats.TotalViewings, stats.AverageViewingRating, tt.wantViewings, tt.wantRating)
+			}
+		})
+	}
+}
+
+func TestReimportIsIdempotent(t *testing.T) {
+	discardLog(t)
+	tests := []struct {
+		name  string
+		films []testFilm
+		extra map[string]string
+	}{
+		{"one film", []testFilm{alien}, nil},
+		{"two films", []testFilm{alien, brazil}, nil},
+		{"same day rewatch", []testFilm{alien}, map[string]string{
+			"diary.csv": "2024-01-02,Alien,1979,https://letterboxd.com/me/film/alien/1/,5,Yes,,2024-01-01\n",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := openTestDB(t)
+			importTestExport(t, testDB, accountExport(t, tt.films, tt.extra))
+			before := countRows(t, testDB)
+			runID := importTestExport(t, testDB, accountExport(t, tt.films, tt.extra))
+
+			if after := countRows(t, testDB); !maps.Equal(after, before) {
+				t.Errorf("rows after re-import = %v, want %v", after, before)
+			}
+			var files []struct {
+				File    string `db:"file"`
+				Added   int    `db:"added"`
+				Updated int    `db:"updated"`
+				Skipped int    `db:"skipped"`
+				Removed int    `db:"removed"`
+			}
+			if err := testDB.Select(&files, `SELECT file, added, updated, skipped, removed FROM import_run_files
+				WHERE run_id = ?`, runID); err != nil {
+				t.Fatal(err)
+			}
+			if len(files) == 0 {
+				t.Fatal("no file recorded for the re-import")
+			}
+			for _, f := range files {
+				if f.Added != 0 || f.Updated != 0 || f.Removed != 0 || f.Skipped == 0 {
+					t.Errorf("%s: %+v, want every row skipped", f.File, f)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDiary(t *testing.T) {
+	discardLog(t)
+	tests := []struct {
+		name string
+		row  string // Ligne de diary.csv pour Alien
+		want DiaryEntry
+	}{
+		{"first watch", "2024-01-02,Alien,1979,https://letterboxd.com/me/film/alien/,4,,,2024-01-01",
+			DiaryEntry{DiaryDate: "2024-01-02", Rating: 4, WatchedDate: "2024-01-01"}},
+		{"rewatch with tags", `2024-01-02,Alien,1979,https://letterboxd.com/me/film/alien/,4.5,Yes,"space, horror",2024-01-01`,
+			DiaryEntry{DiaryDate: "2024-01-02", Rating: 4.5, Rewatch: true, Tags: "space, horror", WatchedDate: "2024-01-01"}},
+		{"without watched date", "2024-01-02,Alien,1979,https://letterboxd.com/me/film/alien/,,,,",
+			DiaryEntry{DiaryDate: "2024-01-02", WatchedDate: "2024-01-02"}},
+		{"invalid watched date", "2024-01-02,Alien,1979,https://letterboxd.com/me/film/alien/,3,,,01/01/2024",
+			DiaryEntry{DiaryDate: "2024-01-02", Rating: 3, WatchedDate: "2024-01-02"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := openTestDB(t)
+			importTestExport(t, testDB, testExport(t, map[string]string{
+				"watched.csv": "Date,Name,Year,Letterboxd URI\n2024-01-01,Alien,1979,https://boxd.it/2b0k\n",
+				"diary.csv":   "Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date\n" + tt.row + "\n",
+			}))
+
+			var got []DiaryEntry
+			if err := testDB.Select(&got, `SELECT 0 AS id, letterboxd_uri, entry_uri, diary_date, IFNULL(rating, 0) AS rating,
+				rewatch, IFNULL(tags, '') AS tags, IFNULL(watched_date, '') AS watched_date FROM diary`); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			want.LetterboxdURI = alien.uri
+			want.EntryURI = alien.entry
+			if len(got) != 1 || got[0] != want {
+				t.Errorf("diary = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// BreakdownStat résume les films vus d'un studio, d'un mot-clé ou d'une langue parlée :
+// nombre de films, note moyenne donnée et durée totale.
+type BreakdownStat struct {
+	ID            string  `json:"id" db:"id"`
+	Name          string  `json:"name" db:"name"`
+	Count         int     `json:"count" db:"count"`
+	AverageRating float64 `json:"average_rating" db:"average_rating"`
+	TotalRuntime  int     `json:"total_runtime" db:"total_runtime"`
+}
+
+// maxBreakdownStats limite les studios et les mots-clés renvoyés, bien plus nombreux que
+// les genres.
+const maxBreakdownStats = 50
+
+// breakdownStats calcule le nombre de films vus, leur note moyenne et leur durée totale
+// pour chaque valeur de idExpr, libellée par nameExpr, dans les tables ajoutées par join.
+// limit vaut 0 pour ne pas limiter le nombre de valeurs.
+func breakdownStats(join, idExpr, nameExpr string, limit int) ([]BreakdownStat, error) {
+	stats := []BreakdownStat{}
+	query := `
+        SELECT CAST(` + idExpr + ` AS TEXT) AS id, ` + nameExpr + ` AS name,
+            COUNT(*) AS count,
+            IFNULL(AVG(r.rating), 0) AS average_rating,
+            IFNULL(SUM(m.runtime), 0) AS total_runtime
+        FROM (SELECT DISTINCT letterboxd_uri FROM watched) w
+        JOIN movies m ON m.letterboxd_uri = w.letterboxd_uri
+        ` + join + `
+        LEFT JOIN ratings r ON r.letterboxd_uri = w.letterboxd_uri
+        GROUP BY ` + idExpr + `
+        ORDER BY count DESC, name`
+	if limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, limit)
+	}
+	err := db.Select(&stats, query)
+	return stats, err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PersonStat résume les films vus d'une personne dans un rôle donné : nombre de films,
+// note moyenne donnée, durée totale et dates du premier et du dernier visionnage.
+type PersonStat struct {
+	ID            int     `json:"id" db:"id"`
+	Name          string  `json:"name" db:"name"`
+	ProfilePath   string  `json:"profile_path" db:"profile_path"`
+	Watched       int     `json:"watched" db:"watched"`
+	AverageRating float64 `json:"average_rating" db:"average_rating"`
+	TotalMinutes  int     `json:"total_minutes" db:"total_minutes"`
+	FirstWatched  string  `json:"first_watched" db:"first_watched"`
+	LastWatched   string  `json:"last_watched" db:"last_watched"`
+}
+
+// crewRoles associe chaque rôle de /api/people/top aux postes TMDB de l'équipe technique.
+// Le rôle actor est lu dans la distribution.
+var crewRoles = map[string][]string{
+	"director":        {"Director"},
+	"cinematographer": {"Director of Photography", "Cinematography"},
+	"composer":        {"Original Music Composer", "Music", "Composer"},
+}
+
+const (
+	defaultPeopleLimit = 10
+	maxPeopleLimit     = 100
+)
+
+// topPeople classe les personnes créditées sur les films vus. credited est une requête
+// renvoyant les couples (person_id, letterboxd_uri) du rôle demandé.
+func topPeople(credited string, args []interface{}, limit int) ([]PersonStat, error) {
+	stats := []PersonStat{}
+	query := `
+        WITH credited AS (` + credited + `),
+        viewings AS (
+            SELECT letterboxd_uri, watched_date FROM watched WHERE watched_date != ''
+            UNION ALL
+            SELECT letterboxd_uri, watched_date FROM diary WHERE watched_date != ''
+        ),
+        films AS (
+            SELECT letterboxd_uri, MIN(watched_date) AS first_watched, MAX(watched_date) AS last_watched
+            FROM viewings GROUP BY letterboxd_uri
+        )
+        SELECT p.id, p.name, IFNULL(p.profile_path, '') AS profile_path,
+            COUNT(*) AS watched,
+            IFNULL(AVG(r.rating), 0) AS average_rating,
+            IFNULL(SUM(m.runtime), 0) AS total_minutes,
+            MIN(f.first_watched) AS first_watched,
+            MAX(f.last_watched) AS last_watched
+        FROM credited c
+        JOIN films f ON f.letterboxd_uri = c.letterboxd_uri
+        JOIN people p ON p.id = c.person_id
+        JOIN movies m ON m.letterboxd_uri = c.letterboxd_uri
+        LEFT JOIN ratings r ON r.letterboxd_uri = c.letterboxd_uri
+        GROUP BY p.id
+        ORDER BY watched DESC, total_minutes DESC, p.name
+        LIMIT ?`
+	err := db.Select(&stats, query, append(args, limit)...)
+	return stats, err
+}
+
+// topPeopleHandler renvoie les personnes les plus vues dans un rôle : role=director (par
+// défaut), cinematographer, composer ou actor. Pour actor, max_order limite le classement
+// aux rôles d'un rang d'affiche inférieur ou égal (0 pour les têtes d'affiche).
+func topPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	limit := defaultPeopleLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPeopleLimit {
+			http.Error(w, `{"error": "Paramètre limit invalide (1 à 100)"}`, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	role := query.Get("role")
+	if role == "" {
+		role = "director"
+	}
+
+	var (
+		credited string
+		args     []interface{}
+		err      error
+	)
+	switch jobs, ok := crewRoles[role]; {
+	case ok:
+		credited, args, err = sqlx.In(`SELECT DISTINCT person_id, letterboxd_uri FROM movie_crew WHERE job IN (?)`, jobs)
+		if err != nil {
+			http.Error(w, `{"error": "Erreur lors du classement des personnes"}`, http.StatusInternalServerError)
+			return
+		}
+	case role == "actor":
+		credited = `SELECT DISTINCT person_id, letterboxd_uri FROM movie_cast`
+		if v := query.Get("max_order"); v != "" {
+			maxOrder, err := strconv.Atoi(v)
+			if err != nil || maxOrder < 0 {
+				http.Error(w, `{"error": "Paramètre max_order invalide"}`, http.StatusBadRequest)
+				return
+			}
+			credited += ` WHERE billing_order <= ?`
+			args = append(args, maxOrder)
+		}
+	default:
+		http.Error(w, `{"error": "Rôle inconnu (director, cinematographer, composer ou actor)"}`, http.StatusBadRequest)
+		return
+	}
+
+	people, err := topPeople(credited, args, limit)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors du classement des personnes"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(people)
+}
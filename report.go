@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ImportIssue est une ligne du rapport de validation d'un import : où le problème a été
+// rencontré (fichier, ligne, colonne), de quel problème il s'agit et ce que l'import en a
+// fait. Row est le numéro de ligne dans le CSV (l'en-tête est la ligne 1) ou, pour le JSON
+// TMDB, la position du film dans le tableau ; il vaut 0 pour un problème touchant tout le
+// fichier.
+type ImportIssue struct {
+	RunID   int64  `json:"-" db:"run_id"`
+	File    string `json:"file" db:"file"`
+	Row     int    `json:"row,omitempty" db:"line"`
+	Column  string `json:"column,omitempty" db:"column_name"`
+	Problem string `json:"problem" db:"problem"`
+	Action  string `json:"action" db:"action"`
+	Message string `json:"message" db:"message"`
+}
+
+// Problèmes signalés dans le rapport d'import.
+const (
+	problemMissingColumn   = "missing_column"   // Colonne obligatoire absente de l'en-tête
+	problemMissingHeader   = "missing_header"   // En-tête de liste introuvable
+	problemFieldCount      = "field_count"      // Ligne n'ayant pas le nombre de champs de l'en-tête
+	problemParseError      = "parse_error"      // CSV ou JSON mal formé
+	problemMissingValue    = "missing_value"    // Valeur obligatoire vide
+	problemInvalidDate     = "invalid_date"     // Date qui n'est pas au format AAAA-MM-JJ
+	problemInvalidYear     = "invalid_year"     // Année qui n'est pas un entier
+	problemInvalidRating   = "invalid_rating"   // Note qui n'est pas un nombre entre 0.5 et 5
+	problemInvalidPosition = "invalid_position" // Position de liste qui n'est pas un entier
+	problemDuplicateID     = "duplicate_id"     // Identifiant externe déjà attribué à un autre film
+	problemReadError       = "read_error"       // Fichier illisible
+	problemDatabaseError   = "database_error"   // Écriture refusée par la base
+	problemImportError     = "import_error"     // Autre erreur
+)
+
+// Actions prises par l'import face à un problème.
+const (
+	actionValueDefaulted = "value_defaulted" // La valeur est remplacée par sa valeur par défaut, la ligne est importée
+	actionRowSkipped     = "row_skipped"     // La ligne n'est pas importée
+	actionFileSkipped    = "file_skipped"    // Aucune ligne du fichier n'est conservée
+	actionRunAborted     = "run_aborted"     // Le run est annulé, la base est inchangée
+	actionNone           = "none"            // Le problème est seulement signalé
+)
+
+// importError est une erreur qui interrompt l'import d'un fichier, avec la ligne et le
+// problème à reporter.
+type importError struct {
+	row     int
+	problem string
+	err     error
+}
+
+func (e *importError) Error() string {
+	if e.row > 0 {
+		return fmt.Sprintf("ligne %d: %v", e.row, e.err)
+	}
+	return e.err.Error()
+}
+
+func (e *importError) Unwrap() error { return e.err }
+
+// missingColumnsError signale les colonnes obligatoires absentes de l'en-tête situé à la
+// ligne line d'un fichier, détectées avant l'écriture de la moindre ligne.
+type missingColumnsError struct {
+	line    int
+	columns []string
+}
+
+func (e *missingColumnsError) Error() string {
+	return fmt.Sprintf("colonnes obligatoires absentes: %s", strings.Join(e.columns, ", "))
+}
+
+// columnIndex associe chaque colonne de l'en-tête à sa position.
+func columnIndex(header []string) map[string]int {
+	colIdx := make(map[string]int, len(header))
+	for i, col := range header {
+		colIdx[col] = i
+	}
+	return colIdx
+}
+
+// missingColumns renvoie les colonnes de required absentes de l'en-tête.
+func missingColumns(colIdx map[string]int, required ...string) []string {
+	var missing []string
+	for _, col := range required {
+		if _, ok := colIdx[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	return missing
+}
+
+// report ajoute un problème au rapport du run et le journalise.
+func (r *importRun) report(issue ImportIssue) {
+	issue.RunID = r.id
+	if issue.Row > 0 {
+		log.Printf("%s:%d: %s (%s)", issue.File, issue.Row, issue.Message, issue.Action)
+	} else {
+		log.Printf("%s: %s (%s)", issue.File, issue.Message, issue.Action)
+	}
+	r.issues = append(r.issues, issue)
+}
+
+// fail reporte l'échec de l'import d'un fichier, dont aucune ligne n'est conservée.
+func (r *importRun) fail(file string, err error) {
+	var missing *missingColumnsError
+	if errors.As(err, &missing) {
+		for _, col := range missing.columns {
+			r.report(ImportIssue{File: file, Row: missing.line, Column: col, Problem: problemMissingColumn,
+				Action: actionFileSkipped, Message: fmt.Sprintf("colonne obligatoire %q absente", col)})
+		}
+		return
+	}
+
+	issue := ImportIssue{File: file, Problem: problemImportError, Action: actionFileSkipped, Message: err.Error()}
+	var (
+		ie        *importError
+		csvErr    *csv.ParseError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		pathErr   *fs.PathError
+	)
+	switch {
+	case errors.As(err, &ie):
+		issue.Row, issue.Problem = ie.row, ie.problem
+	case errors.As(err, &csvErr):
+		issue.Row, issue.Problem = csvErr.Line, problemParseError
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		issue.Problem = problemParseError
+	case errors.As(err, &pathErr):
+		issue.Problem = problemReadError
+	}
+	r.report(issue)
+}
+
+// csvRow donne accès aux champs d'une ligne CSV par nom de colonne et reporte les valeurs
+// invalides dans le rapport du run.
+type csvRow struct {
+	run    *importRun
+	file   string
+	line   int
+	colIdx map[string]int
+	fields []string
+}
+
+// get renvoie la valeur de la première colonne de cols présente dans le fichier.
+func (r csvRow) get(cols ...string) string {
+	for _, col := range cols {
+		if i, ok := r.colIdx[col]; ok && i < len(r.fields) {
+			return r.fields[i]
+		}
+	}
+	return ""
+}
+
+// issue reporte un problème sur une colonne de la ligne.
+func (r csvRow) issue(col, problem, action, format string, args ...interface{}) {
+	r.run.report(ImportIssue{File: r.file, Row: r.line, Column: col, Problem: problem,
+		Action: action, Message: fmt.Sprintf(format, args...)})
+}
+
+// date renvoie la date de la colonne col et indique si elle est au format AAAA-MM-JJ.
+func (r csvRow) date(col string) (string, bool) {
+	v := strings.TrimSpace(r.get(col))
+	_, err := time.Parse(time.DateOnly, v)
+	return v, err == nil
+}
+
+// year renvoie l'année du film. Une année absente vaut 0 ; une année illisible est
+// remplacée par 0 et reportée.
+func (r csvRow) year() int {
+	v := strings.TrimSpace(r.get("Year"))
+	if v == "" {
+		return 0
+	}
+	year, err := strconv.Atoi(v)
+	if err != nil {
+		r.issue("Year", problemInvalidYear, actionValueDefaulted, "année %q illisible, remplacée par 0", v)
+		return 0
+	}
+	return year
+}
+
+// rating renvoie la note de la ligne. Une note absente vaut 0 (film non noté) ; une note
+// illisible ou hors de l'échelle Letterboxd est remplacée par 0 et reportée.
+func (r csvRow) rating() float64 {
+	v := strings.TrimSpace(r.get("Rating"))
+	if v == "" {
+		return 0
+	}
+	rating, err := strconv.ParseFloat(v, 64)
+	if err != nil || rating < 0.5 || rating > 5 {
+		r.issue("Rating", problemInvalidRating, actionValueDefaulted, "note %q invalide (attendu : 0.5 à 5), remplacée par 0", v)
+		return 0
+	}
+	return rating
+}
+
+// importIssues renvoie le rapport d'un run d'import.
+func importIssues(db *sqlx.DB, runID int64) ([]ImportIssue, error) {
+	issues := []ImportIssue{}
+	err := db.Select(&issues, `SELECT run_id, IFNULL(file, '') AS file, IFNULL(line, 0) AS line,
+		IFNULL(column_name, '') AS column_name, IFNULL(problem, '') AS problem, IFNULL(action, '') AS action,
+		IFNULL(message, '') AS message
+		FROM import_run_errors WHERE run_id = ? ORDER BY id`, runID)
+	return issues, err
+}
+
+// writeImportReport écrit le rapport d'un run au format JSON dans le fichier path, ou sur
+// la sortie standard si path vaut "-".
+func writeImportReport(db *sqlx.DB, runID int64, path string) error {
+	issues, err := importIssues(db, runID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// importReportHandler renvoie le rapport de validation d'un import.
+func importReportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error": "Identifiant d'import invalide"}`, http.StatusBadRequest)
+		return
+	}
+
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM import_runs WHERE id = ?`, id); err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération de l'import"}`, http.StatusInternalServerError)
+		return
+	}
+	if count == 0 {
+		http.Error(w, `{"error": "Import introuvable"}`, http.StatusNotFound)
+		return
+	}
+
+	issues, err := importIssues(db, id)
+	if err != nil {
+		http.Error(w, `{"error": "Erreur lors de la récupération du rapport d'import"}`, http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(issues)
+}
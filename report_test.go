@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestImportReport(t *testing.T) {
+	discardLog(t)
+	const header = "Date,Name,Year,Letterboxd URI,Rating,Rewatch,Tags,Watched Date\n"
+	tests := []struct {
+		name  string
+		files map[string]string // Fichiers ajoutés à un watched.csv valide
+		want  []ImportIssue     // Sans message
+		rows  int               // Lignes de diary après l'import
+	}{
+		{"valid", map[string]string{
+			"diary.csv": header + "2024-01-02,Alien,1979,https://boxd.it/a1,4,,,2024-01-01\n",
+		}, nil, 1},
+		{"missing column", map[string]string{
+			"diary.csv": "Date,Name,Year,Rating,Rewatch,Tags\n2024-01-02,Alien,1979,4,,\n",
+		}, []ImportIssue{{File: "diary.csv", Row: 1, Column: "Letterboxd URI", Problem: problemMissingColumn, Action: actionFileSkipped}}, 0},
+		{"field count", map[string]string{
+			"diary.csv": header + "2024-01-02,Alien,1979\n",
+		}, []ImportIssue{{File: "diary.csv", Row: 2, Problem: problemFieldCount, Action: actionRowSkipped}}, 0},
+		{"missing URI", map[string]string{
+			"diary.csv": header + "2024-01-02,Alien,1979,,4,,,\n",
+		}, []ImportIssue{{File: "diary.csv", Row: 2, Column: "Letterboxd URI", Problem: problemMissingValue, Action: actionRowSkipped}}, 0},
+		{"invalid date", map[string]string{
+			"diary.csv": header + "02/01/2024,Alien,1979,https://boxd.it/a1,4,,,\n",
+		}, []ImportIssue{{File: "diary.csv", Row: 2, Column: "Date", Problem: problemInvalidDate, Action: actionRowSkipped}}, 0},
+		{"invalid watched date", map[string]string{
+			"diary.csv": header + "2024-01-02,Alien,1979,https://boxd.it/a1,4,,,yesterday\n",
+		}, []ImportIssue{{File: "diary.csv", Row: 2, Column: "Watched Date", Problem: problemInvalidDate, Action: actionValueDefaulted}}, 1},
+		{"invalid rating and year", map[string]string{
+			"diary.csv": header + "2024-01-02,Alien,MCMLXXIX,https://boxd.it/a1,9,,,\n",
+		}, []ImportIssue{
+			{File: "diary.csv", Row: 2, Column: "Year", Problem: problemInvalidYear, Action: actionValueDefaulted},
+			{File: "diary.csv", Row: 2, Column: "Rating", Problem: problemInvalidRating, Action: actionValueDefaulted},
+		}, 1},
+		{"malformed CSV", map[string]string{
+			"diary.csv": header + "2024-01-02,\"Alien,1979,https://boxd.it/a1,4,,,\n",
+		}, []ImportIssue{{File: "diary.csv", Row: 2, Problem: problemParseError, Action: actionFileSkipped}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB := openTestDB(t)
+			tt.files["watched.csv"] = "Date,Name,Year,Letterboxd URI\n2024-01-02,Alien,1979,https://boxd.it/2b0k\n"
+			runID := importTestExport(t, testDB, testExport(t, tt.files))
+
+			issues, err := importIssues(testDB, runID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []ImportIssue
+			for _, issue := range issues {
+				issue.RunID, issue.Message = 0, ""
+				got = append(got, issue)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("issues = %+v, want %+v", got, tt.want)
+			}
+			if n := countRows(t, testDB)["diary"]; n != tt.rows {
+				t.Errorf("%d diary rows, want %d", n, tt.rows)
+			}
+		})
+	}
+}
+
+func TestImportReportHandler(t *testing.T) {
+	discardLog(t)
+	testDB := openTestDB(t)
+	useTestDB(t, testDB)
+	runID := importTestExport(t, testDB, testExport(t, map[string]string{
+		"watched.csv": "Date,Name,Year,Letterboxd URI\n2024-01-02,Alien,MCMLXXIX,https://boxd.it/2b0k\n",
+	}))
+
+	tests := []struct {
+		id         string
+		wantStatus int
+		wantIssues int
+	}{
+		{fmt.Sprint(runID), http.StatusOK, 1},
+		{fmt.Sprint(runID + 1), http.StatusNotFound, 0},
+		{"latest", http.StatusBadRequest, 0},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/imports/{id}/report", importReportHandler)
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/imports/"+tt.id+"/report", nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("report %s: status %d, want %d", tt.id, rec.Code, tt.wantStatus)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var issues []ImportIssue
+		if err := json.NewDecoder(rec.Body).Decode(&issues); err != nil {
+			t.Fatal(err)
+		}
+		if len(issues) != tt.wantIssues {
+			t.Errorf("report %s: %d issues, want %d", tt.id, len(issues), tt.wantIssues)
+		}
+	}
+}
@@ -87,6 +87,22 @@ class LetterboxdStats {
             
             document.getElementById('top-countries').textContent = topCountries || 'None';
         }
+
+        // Display the most watched genres
+        if (statistics.genres && statistics.genres.length > 0) {
+            const topGenres = statistics.genres
+                .slice(0, 3)
+                .map(genre => `${genre.genre} (${genre.count})`)
+                .join(', ');
+
+            document.getElementById('top-genres').textContent = topGenres;
+        }
+
+        // Display diary viewings, rewatches included
+        if (statistics.total_viewings) {
+            document.getElementById('total-viewings').textContent = statistics.total_viewings;
+            document.getElementById('rewatches').textContent = `${statistics.rewatches} rewatches`;
+        }
     }
 
     // Modify your init method to include statistics
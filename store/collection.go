@@ -0,0 +1,90 @@
+package store
+
+import "slices"
+
+// Collection représente une collection TMDB (une saga) et ses films dans l'ordre de sortie.
+type Collection struct {
+	ID         int
+	Name       string
+	PosterPath string
+	FetchedAt  string // Date de récupération (RFC 3339)
+	Parts      []CollectionPart
+}
+
+// CollectionPart est un film d'une collection, ligne de collection_parts. Position est son
+// rang dans l'ordre de sortie, à partir de 0.
+type CollectionPart struct {
+	TMDBID      int    `db:"tmdb_id"`
+	Position    int    `db:"position"`
+	Title       string `db:"title"`
+	ReleaseDate string `db:"release_date"`
+	PosterPath  string `db:"poster_path"`
+}
+
+// CollectionFetchTimes renvoie la date de récupération des collections déjà enregistrées,
+// vide si elle est inconnue, par identifiant.
+func CollectionFetchTimes(db DBTX) (map[int]string, error) {
+	var rows []struct {
+		ID        int    `db:"id"`
+		FetchedAt string `db:"fetched_at"`
+	}
+	if err := db.Select(&rows, `SELECT id, IFNULL(fetched_at, '') AS fetched_at FROM collections`); err != nil {
+		return nil, err
+	}
+	known := make(map[int]string, len(rows))
+	for _, r := range rows {
+		known[r.ID] = r.FetchedAt
+	}
+	return known, nil
+}
+
+// SaveCollection enregistre une collection et ses films, numérotés dans l'ordre de Parts
+// (un film en double n'est gardé qu'à sa première place), et indique si elle a changé. Les
+// films ne sont réécrits que s'ils ont changé.
+func SaveCollection(db DBTX, c Collection) (bool, error) {
+	var parts []CollectionPart
+	for _, p := range c.Parts {
+		if slices.ContainsFunc(parts, func(q CollectionPart) bool { return q.TMDBID == p.TMDBID }) {
+			continue
+		}
+		p.Position = len(parts)
+		parts = append(parts, p)
+	}
+
+	res, err := db.Exec(`INSERT INTO collections (id, name, poster_path, fetched_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, poster_path = excluded.poster_path,
+			fetched_at = excluded.fetched_at
+		WHERE name IS NOT excluded.name OR poster_path IS NOT excluded.poster_path
+			OR fetched_at IS NOT excluded.fetched_at`,
+		c.ID, c.Name, c.PosterPath, c.FetchedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	changed := n > 0
+
+	var current []CollectionPart
+	if err := db.Select(&current, `SELECT tmdb_id, position, IFNULL(title, '') AS title,
+		IFNULL(release_date, '') AS release_date, IFNULL(poster_path, '') AS poster_path
+		FROM collection_parts WHERE collection_id = ? ORDER BY position`, c.ID); err != nil {
+		return false, err
+	}
+	if slices.Equal(current, parts) {
+		return changed, nil
+	}
+
+	if _, err := db.Exec(`DELETE FROM collection_parts WHERE collection_id = ?`, c.ID); err != nil {
+		return false, err
+	}
+	for _, p := range parts {
+		if _, err := db.Exec(`INSERT INTO collection_parts
+			(collection_id, tmdb_id, position, title, release_date, poster_path) VALUES (?, ?, ?, ?, ?, ?)`,
+			c.ID, p.TMDBID, p.Position, p.Title, p.ReleaseDate, p.PosterPath); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// movieCountry est un pays de production d'un film.
+type movieCountry struct {
+	ISO3166_1 string `db:"iso_3166_1"`
+	IsPrimary bool   `db:"is_primary"`
+}
+
+// syncMovieCountries enregistre les pays de production d'un film, le premier étant le pays
+// principal, et indique s'ils ont changé depuis le dernier import.
+func syncMovieCountries(db DBTX, uri string, countries []ProductionCountry) (bool, error) {
+	var current []movieCountry
+	if err := db.Select(&current, `SELECT iso_3166_1, is_primary FROM movie_countries
+		WHERE letterboxd_uri = ? ORDER BY is_primary DESC, iso_3166_1`, uri); err != nil {
+		return false, err
+	}
+	var next []movieCountry
+	for _, pc := range countries {
+		if pc.ISO3166_1 == "" || slices.ContainsFunc(next, func(c movieCountry) bool { return c.ISO3166_1 == pc.ISO3166_1 }) {
+			continue
+		}
+		if _, err := db.Exec(`INSERT INTO countries (iso_3166_1, name) VALUES (?, ?)
+			ON CONFLICT (iso_3166_1) DO UPDATE SET name = excluded.name WHERE name IS NOT excluded.name`,
+			pc.ISO3166_1, pc.Name); err != nil {
+			return false, err
+		}
+		next = append(next, movieCountry{ISO3166_1: pc.ISO3166_1, IsPrimary: len(next) == 0})
+	}
+	if len(next) > 1 {
+		slices.SortFunc(next[1:], func(a, b movieCountry) int { return strings.Compare(a.ISO3166_1, b.ISO3166_1) })
+	}
+	if slices.Equal(current, next) {
+		return false, nil
+	}
+
+	if _, err := db.Exec(`DELETE FROM movie_countries WHERE letterboxd_uri = ?`, uri); err != nil {
+		return false, err
+	}
+	for _, c := range next {
+		if _, err := db.Exec(`INSERT INTO movie_countries (letterboxd_uri, iso_3166_1, is_primary) VALUES (?, ?, ?)`,
+			uri, c.ISO3166_1, c.IsPrimary); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
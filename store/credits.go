@@ -0,0 +1,128 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// Credits représente le générique TMDB d'un film (append_to_response=credits).
+type Credits struct {
+	Cast []CastCredit `json:"cast"`
+	Crew []CrewCredit `json:"crew"`
+}
+
+// Person représente une personne TMDB créditée sur un film.
+type Person struct {
+	ID                 int    `json:"id" db:"id"`
+	Name               string `json:"name" db:"name"`
+	ProfilePath        string `json:"profile_path" db:"profile_path"`
+	KnownForDepartment string `json:"known_for_department" db:"known_for_department"`
+}
+
+// CastCredit est un rôle dans la distribution d'un film. Order est l'ordre d'affiche,
+// 0 pour la tête d'affiche.
+type CastCredit struct {
+	Person
+	CreditID  string `json:"credit_id" db:"credit_id"`
+	Character string `json:"character" db:"character"`
+	Order     int    `json:"order" db:"billing_order"`
+}
+
+// CrewCredit est un poste dans l'équipe technique d'un film.
+type CrewCredit struct {
+	Person
+	CreditID   string `json:"credit_id" db:"credit_id"`
+	Job        string `json:"job" db:"job"`
+	Department string `json:"department" db:"department"`
+}
+
+// castRow et crewRow sont les lignes de movie_cast et movie_crew d'un film, comparées
+// d'un import à l'autre.
+type castRow struct {
+	CreditID  string `db:"credit_id"`
+	PersonID  int    `db:"person_id"`
+	Character string `db:"character"`
+	Order     int    `db:"billing_order"`
+}
+
+type crewRow struct {
+	CreditID   string `db:"credit_id"`
+	PersonID   int    `db:"person_id"`
+	Job        string `db:"job"`
+	Department string `db:"department"`
+}
+
+// syncMovieCredits enregistre le générique d'un film et indique s'il a changé depuis le
+// dernier import. Un JSON produit avant la récupération du générique n'en a pas : le
+// générique déjà en base est alors conservé.
+func syncMovieCredits(db DBTX, uri string, credits *Credits) (bool, error) {
+	if credits == nil {
+		return false, nil
+	}
+
+	var cast []castRow
+	for _, c := range credits.Cast {
+		if err := upsertPerson(db, c.Person); err != nil {
+			return false, err
+		}
+		cast = append(cast, castRow{CreditID: c.CreditID, PersonID: c.ID, Character: c.Character, Order: c.Order})
+	}
+	var crew []crewRow
+	for _, c := range credits.Crew {
+		if err := upsertPerson(db, c.Person); err != nil {
+			return false, err
+		}
+		crew = append(crew, crewRow{CreditID: c.CreditID, PersonID: c.ID, Job: c.Job, Department: c.Department})
+	}
+	slices.SortFunc(cast, func(a, b castRow) int { return strings.Compare(a.CreditID, b.CreditID) })
+	cast = slices.CompactFunc(cast, func(a, b castRow) bool { return a.CreditID == b.CreditID })
+	slices.SortFunc(crew, func(a, b crewRow) int { return strings.Compare(a.CreditID, b.CreditID) })
+	crew = slices.CompactFunc(crew, func(a, b crewRow) bool { return a.CreditID == b.CreditID })
+
+	var currentCast []castRow
+	if err := db.Select(&currentCast, `SELECT credit_id, person_id, IFNULL(character, '') AS character,
+		IFNULL(billing_order, 0) AS billing_order
+		FROM movie_cast WHERE letterboxd_uri = ? ORDER BY credit_id`, uri); err != nil {
+		return false, err
+	}
+	var currentCrew []crewRow
+	if err := db.Select(&currentCrew, `SELECT credit_id, person_id, IFNULL(job, '') AS job,
+		IFNULL(department, '') AS department
+		FROM movie_crew WHERE letterboxd_uri = ? ORDER BY credit_id`, uri); err != nil {
+		return false, err
+	}
+	if slices.Equal(currentCast, cast) && slices.Equal(currentCrew, crew) {
+		return false, nil
+	}
+
+	if _, err := db.Exec(`DELETE FROM movie_cast WHERE letterboxd_uri = ?`, uri); err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(`DELETE FROM movie_crew WHERE letterboxd_uri = ?`, uri); err != nil {
+		return false, err
+	}
+	for _, c := range cast {
+		if _, err := db.Exec(`INSERT INTO movie_cast (letterboxd_uri, credit_id, person_id, character, billing_order)
+			VALUES (?, ?, ?, ?, ?)`, uri, c.CreditID, c.PersonID, c.Character, c.Order); err != nil {
+			return false, err
+		}
+	}
+	for _, c := range crew {
+		if _, err := db.Exec(`INSERT INTO movie_crew (letterboxd_uri, credit_id, person_id, job, department)
+			VALUES (?, ?, ?, ?, ?)`, uri, c.CreditID, c.PersonID, c.Job, c.Department); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// upsertPerson enregistre une personne ou met à jour son nom et sa photo.
+func upsertPerson(db DBTX, p Person) error {
+	_, err := db.Exec(`INSERT INTO people (id, name, profile_path, known_for_department) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, profile_path = excluded.profile_path,
+			known_for_department = excluded.known_for_department
+		WHERE name IS NOT excluded.name OR profile_path IS NOT excluded.profile_path
+			OR known_for_department IS NOT excluded.known_for_department`,
+		p.ID, p.Name, p.ProfilePath, p.KnownForDepartment)
+	return err
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// Filmography est la filmographie TMDB d'une personne (person/{id}/movie_credits).
+type Filmography struct {
+	PersonID  int
+	Name      string
+	FetchedAt string // Date de récupération (RFC 3339)
+	Credits   []FilmographyCredit
+}
+
+// FilmographyCredit est un crédit de la filmographie d'une personne, ligne de
+// person_filmography : rôle dans la distribution (Kind "cast") ou poste dans l'équipe
+// technique ("crew"). TMDBID est l'identifiant TMDB du film.
+type FilmographyCredit struct {
+	CreditID    string `db:"credit_id"`
+	TMDBID      int    `db:"tmdb_id"`
+	Title       string `db:"title"`
+	ReleaseDate string `db:"release_date"`
+	Kind        string `db:"kind"`
+	Job         string `db:"job"`
+	Character   string `db:"character"`
+	Runtime     int    `db:"runtime"` // 0 si elle n'est pas encore connue
+	Video       bool   `db:"video"`
+	TVMovie     bool   `db:"tv_movie"`
+	Uncredited  bool   `db:"uncredited"`
+}
+
+// FilmographyFetchTimes renvoie la date de récupération des filmographies déjà
+// enregistrées, par identifiant de personne.
+func FilmographyFetchTimes(db DBTX) (map[int]string, error) {
+	var rows []struct {
+		ID        int    `db:"id"`
+		FetchedAt string `db:"filmography_fetched_at"`
+	}
+	if err := db.Select(&rows, `SELECT id, filmography_fetched_at FROM people WHERE filmography_fetched_at IS NOT NULL`); err != nil {
+		return nil, err
+	}
+	fetched := make(map[int]string, len(rows))
+	for _, r := range rows {
+		fetched[r.ID] = r.FetchedAt
+	}
+	return fetched, nil
+}
+
+// Runtimes renvoie la durée connue des films TMDB, d'après leurs détails ou celles déjà
+// récupérées pour les filmographies (movie_runtimes), par identifiant TMDB.
+func Runtimes(db DBTX) (map[int]int, error) {
+	var rows []struct {
+		TMDBID  int `db:"tmdb_id"`
+		Runtime int `db:"runtime"`
+	}
+	if err := db.Select(&rows, `SELECT tmdb_id, MAX(runtime) AS runtime FROM (
+			SELECT tmdb_id, runtime FROM movies WHERE tmdb_id IS NOT NULL AND runtime > 0
+			UNION ALL
+			SELECT tmdb_id, runtime FROM movie_runtimes
+		) GROUP BY tmdb_id`); err != nil {
+		return nil, err
+	}
+	runtimes := make(map[int]int, len(rows))
+	for _, r := range rows {
+		runtimes[r.TMDBID] = r.Runtime
+	}
+	return runtimes, nil
+}
+
+// MissingRuntimes renvoie les films des filmographies dont la durée n'a pas encore été
+// enregistrée par SaveRuntime.
+func MissingRuntimes(db DBTX) ([]int, error) {
+	var ids []int
+	err := db.Select(&ids, `SELECT DISTINCT tmdb_id FROM person_filmography
+		WHERE tmdb_id NOT IN (SELECT tmdb_id FROM movie_runtimes) ORDER BY tmdb_id`)
+	return ids, err
+}
+
+// SaveRuntime enregistre la durée d'un film TMDB (0 si TMDB ne la connaît pas) et la
+// reporte sur les filmographies qui le contiennent.
+func SaveRuntime(db DBTX, tmdbID, runtime int) error {
+	if _, err := db.Exec(`INSERT INTO movie_runtimes (tmdb_id, runtime) VALUES (?, ?)
+		ON CONFLICT (tmdb_id) DO UPDATE SET runtime = excluded.runtime`, tmdbID, runtime); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE person_filmography SET runtime = ? WHERE tmdb_id = ? AND runtime IS NOT ?`,
+		runtime, tmdbID, runtime)
+	return err
+}
+
+// SaveFilmography enregistre la filmographie d'une personne et indique si elle a changé :
+// ses crédits ne sont remplacés que s'ils ont changé.
+func SaveFilmography(db DBTX, f Filmography) (bool, error) {
+	credits := slices.Clone(f.Credits)
+	slices.SortFunc(credits, func(a, b FilmographyCredit) int { return strings.Compare(a.CreditID, b.CreditID) })
+	credits = slices.CompactFunc(credits, func(a, b FilmographyCredit) bool { return a.CreditID == b.CreditID })
+
+	if _, err := db.Exec(`INSERT INTO people (id, name) VALUES (?, ?) ON CONFLICT (id) DO NOTHING`, f.PersonID, f.Name); err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(`UPDATE people SET filmography_fetched_at = ? WHERE id = ?`, f.FetchedAt, f.PersonID); err != nil {
+		return false, err
+	}
+
+	var current []FilmographyCredit
+	if err := db.Select(&current, `SELECT credit_id, tmdb_id, IFNULL(title, '') AS title,
+		IFNULL(release_date, '') AS release_date, kind, IFNULL(job, '') AS job,
+		IFNULL(character, '') AS character, IFNULL(runtime, 0) AS runtime, video, tv_movie, uncredited
+		FROM person_filmography WHERE person_id = ? ORDER BY credit_id`, f.PersonID); err != nil {
+		return false, err
+	}
+	if slices.Equal(current, credits) {
+		return false, nil
+	}
+
+	if _, err := db.Exec(`DELETE FROM person_filmography WHERE person_id = ?`, f.PersonID); err != nil {
+		return false, err
+	}
+	for _, c := range credits {
+		// Un crédit partagé entre deux personnes n'existe pas chez TMDB, mais une ancienne
+		// filmographie peut en contenir : la dernière enregistrée l'emporte
+		if _, err := db.Exec(`INSERT OR REPLACE INTO person_filmography
+			(credit_id, person_id, tmdb_id, title, release_date, kind, job, character, runtime, video, tv_movie, uncredited)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			c.CreditID, f.PersonID, c.TMDBID, c.Title, c.ReleaseDate, c.Kind, c.Job, c.Character, c.Runtime,
+			c.Video, c.TVMovie, c.Uncredited); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
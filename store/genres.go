@@ -0,0 +1,21 @@
+package store
+
+// Genre représente un genre TMDB.
+type Genre struct {
+	ID   int    `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+}
+
+// syncMovieGenres enregistre les genres d'un film et indique s'ils ont changé depuis le
+// dernier import.
+func syncMovieGenres(db DBTX, uri string, genres []Genre) (bool, error) {
+	ids := make([]int, 0, len(genres))
+	for _, g := range genres {
+		if _, err := db.Exec(`INSERT INTO genres (id, name) VALUES (?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name WHERE name IS NOT excluded.name`, g.ID, g.Name); err != nil {
+			return false, err
+		}
+		ids = append(ids, g.ID)
+	}
+	return syncMovieRefs(db, "movie_genres", "genre_id", uri, ids)
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Keyword représente un mot-clé TMDB (append_to_response=keywords).
+type Keyword struct {
+	ID   int    `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+}
+
+// Keywords est l'objet keywords des détails TMDB d'un film.
+type Keywords struct {
+	Keywords []Keyword `json:"keywords"`
+}
+
+// ProductionCompany représente une société de production (studio) TMDB.
+type ProductionCompany struct {
+	ID            int    `json:"id" db:"id"`
+	Name          string `json:"name" db:"name"`
+	LogoPath      string `json:"logo_path" db:"logo_path"`
+	OriginCountry string `json:"origin_country" db:"origin_country"`
+}
+
+// SpokenLanguage représente une langue parlée dans un film, identifiée par son code ISO 639-1.
+type SpokenLanguage struct {
+	ISO639_1    string `json:"iso_639_1" db:"iso_639_1"`
+	EnglishName string `json:"english_name" db:"english_name"`
+	Name        string `json:"name" db:"name"`
+}
+
+// syncMovieKeywords enregistre les mots-clés d'un film et indique s'ils ont changé depuis
+// le dernier import. Un JSON produit avant la récupération des mots-clés n'en a pas : ceux
+// déjà en base sont alors conservés.
+func syncMovieKeywords(db DBTX, uri string, keywords *Keywords) (bool, error) {
+	if keywords == nil {
+		return false, nil
+	}
+	var ids []int
+	for _, k := range keywords.Keywords {
+		if _, err := db.Exec(`INSERT INTO keywords (id, name) VALUES (?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name WHERE name IS NOT excluded.name`, k.ID, k.Name); err != nil {
+			return false, err
+		}
+		ids = append(ids, k.ID)
+	}
+	return syncMovieRefs(db, "movie_keywords", "keyword_id", uri, ids)
+}
+
+// syncMovieCompanies enregistre les sociétés de production d'un film et indique si elles
+// ont changé depuis le dernier import.
+func syncMovieCompanies(db DBTX, uri string, companies []ProductionCompany) (bool, error) {
+	var ids []int
+	for _, c := range companies {
+		if _, err := db.Exec(`INSERT INTO companies (id, name, logo_path, origin_country) VALUES (?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name, logo_path = excluded.logo_path,
+				origin_country = excluded.origin_country
+			WHERE name IS NOT excluded.name OR logo_path IS NOT excluded.logo_path
+				OR origin_country IS NOT excluded.origin_country`,
+			c.ID, c.Name, c.LogoPath, c.OriginCountry); err != nil {
+			return false, err
+		}
+		ids = append(ids, c.ID)
+	}
+	return syncMovieRefs(db, "movie_companies", "company_id", uri, ids)
+}
+
+// syncMovieLanguages enregistre les langues parlées d'un film et indique si elles ont
+// changé depuis le dernier import.
+func syncMovieLanguages(db DBTX, uri string, languages []SpokenLanguage) (bool, error) {
+	var codes []string
+	for _, l := range languages {
+		if l.ISO639_1 == "" {
+			continue
+		}
+		if _, err := db.Exec(`INSERT INTO languages (iso_639_1, english_name, name) VALUES (?, ?, ?)
+			ON CONFLICT (iso_639_1) DO UPDATE SET english_name = excluded.english_name, name = excluded.name
+			WHERE english_name IS NOT excluded.english_name OR name IS NOT excluded.name`,
+			l.ISO639_1, l.EnglishName, l.Name); err != nil {
+			return false, err
+		}
+		codes = append(codes, l.ISO639_1)
+	}
+	return syncMovieRefs(db, "movie_spoken_languages", "iso_639_1", uri, codes)
+}
+
+// syncMovieRefs remplace les lignes (letterboxd_uri, column) de table d'un film par refs si
+// elles ont changé, et indique si c'est le cas.
+func syncMovieRefs[T cmp.Ordered](db DBTX, table, column, uri string, refs []T) (bool, error) {
+	var current []T
+	if err := db.Select(&current, fmt.Sprintf(`SELECT %s FROM %s WHERE letterboxd_uri = ? ORDER BY %s`,
+		column, table, column), uri); err != nil {
+		return false, err
+	}
+	refs = slices.Clone(refs)
+	slices.Sort(refs)
+	refs = slices.Compact(refs)
+	if slices.Equal(current, refs) {
+		return false, nil
+	}
+
+	if _, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE letterboxd_uri = ?`, table), uri); err != nil {
+		return false, err
+	}
+	for _, ref := range refs {
+		if _, err := db.Exec(fmt.Sprintf(`INSERT INTO %s (letterboxd_uri, %s) VALUES (?, ?)`, table, column), uri, ref); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
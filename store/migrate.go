@@ -0,0 +1,227 @@
+package store
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// migrationFiles contient les migrations du schéma, nommées <version>_<nom>.sql. Une
+// migration publiée ne doit plus être modifiée : toute évolution passe par un nouveau fichier.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration est une évolution du schéma, appliquée une seule fois et dans l'ordre des versions.
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations lit les migrations embarquées, triées par version.
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var migrations []migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, label, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: nom attendu <version>_<nom>.sql", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: label, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("deux migrations portent la version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// tableExists indique si la base contient la table donnée.
+func tableExists(db DBTX, table string) (bool, error) {
+	var count int
+	err := db.Get(&count, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table)
+	return count > 0, err
+}
+
+// appliedMigrations renvoie la date d'application de chaque version déjà appliquée.
+func appliedMigrations(db *sqlx.DB) (map[int]string, error) {
+	applied := make(map[int]string)
+	exists, err := tableExists(db, "schema_version")
+	if err != nil || !exists {
+		return applied, err
+	}
+	var rows []struct {
+		Version   int    `db:"version"`
+		AppliedAt string `db:"applied_at"`
+	}
+	if err := db.Select(&rows, `SELECT version, IFNULL(applied_at, '') AS applied_at FROM schema_version`); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
+
+// Migrate met le schéma à jour au démarrage : chaque migration en attente est appliquée
+// dans sa propre transaction, avec l'enregistrement de sa version dans schema_version.
+func Migrate(db *sqlx.DB) error {
+	exists, err := tableExists(db, "schema_version")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if err := adoptLegacySchema(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("mise à niveau de la base existante: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE TABLE schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT,
+			applied_at TEXT
+		)`); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(m.SQL); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.Version, m.Name, time.Now().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Migration %04d_%s appliquée", m.Version, m.Name)
+	}
+	return nil
+}
+
+// PrintMigrationStatus écrit la liste des migrations, appliquées ou en attente, sans
+// modifier la base.
+func PrintMigrationStatus(db *sqlx.DB, w io.Writer) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, m := range migrations {
+		if at, ok := applied[m.Version]; ok {
+			fmt.Fprintf(w, "%04d_%s\tappliquée le %s\n", m.Version, m.Name, at)
+			continue
+		}
+		pending++
+		fmt.Fprintf(w, "%04d_%s\ten attente\n", m.Version, m.Name)
+	}
+	fmt.Fprintf(w, "%d migration(s) en attente\n", pending)
+	return nil
+}
+
+// adoptLegacySchema met à niveau une base créée avant les migrations, par createTables :
+// tables movies, watched, watchlist, reviews, ratings et comments, sans index. La migration
+// initiale crée les autres tables ; il reste à ajouter aux critiques et commentaires l'URI
+// de leur entrée de journal, et à supprimer les doublons laissés par les imports de l'époque,
+// qui empêcheraient la création des index uniques. Sur une base vide, il n'y a rien à faire.
+func adoptLegacySchema(db DBTX) error {
+	for _, table := range []string{"reviews", "comments"} {
+		exists, err := tableExists(db, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if err := addColumnIfMissing(db, table, "entry_uri", "TEXT"); err != nil {
+			return err
+		}
+		// Les URI des critiques et commentaires de l'époque sont des URI d'entrée de journal
+		if _, err := db.Exec(fmt.Sprintf(`UPDATE %s SET entry_uri = letterboxd_uri WHERE entry_uri IS NULL`, table)); err != nil {
+			return err
+		}
+	}
+
+	naturalKeys := []struct{ table, columns string }{
+		{"watched", "letterboxd_uri, watched_date"},
+		{"watchlist", "letterboxd_uri, added_date"},
+		{"ratings", "letterboxd_uri, rating_date"},
+		{"reviews", "entry_uri, review_date"},
+		{"comments", "entry_uri, comment_date, comment"},
+	}
+	for _, k := range naturalKeys {
+		exists, err := tableExists(db, k.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id NOT IN (SELECT MIN(id) FROM %s GROUP BY %s)`,
+			k.table, k.table, k.columns)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing ajoute une colonne à une table existante si elle n'y figure pas encore.
+func addColumnIfMissing(db DBTX, table, column, decl string) error {
+	var count int
+	err := db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, decl))
+	return err
+}
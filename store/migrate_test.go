@@ -0,0 +1,87 @@
+package store
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+)
+
+// legacySchema est le schéma créé par createTables avant les migrations, avec les doublons
+// qu'y laissaient les imports répétés.
+const legacySchema = `
+CREATE TABLE movies (letterboxd_uri TEXT PRIMARY KEY, title TEXT, original_title TEXT, overview TEXT,
+	release_date TEXT, poster_path TEXT, popularity REAL, vote_average REAL, vote_count INTEGER, adult BOOLEAN,
+	original_language TEXT, runtime INTEGER, tagline TEXT, status TEXT, source TEXT, year INTEGER,
+	main_production_country TEXT, other_production_countries TEXT);
+CREATE TABLE watched (id INTEGER PRIMARY KEY AUTOINCREMENT, letterboxd_uri TEXT, watched_date TEXT);
+CREATE TABLE watchlist (id INTEGER PRIMARY KEY AUTOINCREMENT, letterboxd_uri TEXT, added_date TEXT);
+CREATE TABLE reviews (id INTEGER PRIMARY KEY AUTOINCREMENT, letterboxd_uri TEXT, review_date TEXT, rating REAL,
+	rewatch BOOLEAN, review TEXT, tags TEXT, watched_date TEXT);
+CREATE TABLE ratings (id INTEGER PRIMARY KEY AUTOINCREMENT, letterboxd_uri TEXT, rating_date TEXT, rating REAL);
+CREATE TABLE comments (id INTEGER PRIMARY KEY AUTOINCREMENT, letterboxd_uri TEXT, comment_date TEXT, comment TEXT);
+
+INSERT INTO movies (letterboxd_uri, title, year) VALUES ('https://boxd.it/2b0k', 'Alien', 1979);
+INSERT INTO watched (letterboxd_uri, watched_date) VALUES
+	('https://boxd.it/2b0k', '2024-01-02'), ('https://boxd.it/2b0k', '2024-01-02');
+INSERT INTO reviews (letterboxd_uri, review_date, rating, review) VALUES
+	('https://letterboxd.com/me/film/alien/', '2024-01-02', 4, 'Great'),
+	('https://letterboxd.com/me/film/alien/', '2024-01-02', 4, 'Great');
+`
+
+func TestMigrate(t *testing.T) {
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(output) })
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		schema  string
+		watched int // Lignes de watched après la migration
+		reviews int
+	}{
+		{"empty database", "", 0, 0},
+		{"legacy database", legacySchema, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(filepath.Join(t.TempDir(), "movies.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if _, err := db.Exec(tt.schema); err != nil {
+				t.Fatal(err)
+			}
+
+			// Une deuxième exécution n'a plus rien à appliquer
+			for i := 0; i < 2; i++ {
+				if err := Migrate(db); err != nil {
+					t.Fatalf("Migrate #%d: %v", i+1, err)
+				}
+			}
+			var applied int
+			if err := db.Get(&applied, `SELECT COUNT(*) FROM schema_version`); err != nil {
+				t.Fatal(err)
+			}
+			if applied != len(migrations) {
+				t.Errorf("%d migrations applied, want %d", applied, len(migrations))
+			}
+
+			var watched, reviews int
+			if err := db.Get(&watched, `SELECT COUNT(*) FROM watched`); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Get(&reviews, `SELECT COUNT(*) FROM reviews WHERE entry_uri = letterboxd_uri`); err != nil {
+				t.Fatal(err)
+			}
+			if watched != tt.watched || reviews != tt.reviews {
+				t.Errorf("%d watched rows, %d reviews with their entry URI; want %d, %d", watched, reviews, tt.watched, tt.reviews)
+			}
+		})
+	}
+}
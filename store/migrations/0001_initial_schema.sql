@@ -0,0 +1,161 @@
+-- Schéma de départ. Les bases créées avant les migrations ont déjà ces tables, d'où les
+-- IF NOT EXISTS : adoptLegacySchema les a mises à niveau avant cette migration.
+
+CREATE TABLE IF NOT EXISTS movies (
+    letterboxd_uri TEXT PRIMARY KEY,
+    title TEXT,
+    original_title TEXT,
+    overview TEXT,
+    release_date TEXT,
+    poster_path TEXT,
+    popularity REAL,
+    vote_average REAL,
+    vote_count INTEGER,
+    adult BOOLEAN,
+    original_language TEXT,
+    runtime INTEGER,
+    tagline TEXT,
+    status TEXT,
+    source TEXT,
+    year INTEGER,
+    main_production_country TEXT,
+    other_production_countries TEXT
+);
+
+CREATE TABLE IF NOT EXISTS watched (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    letterboxd_uri TEXT,
+    watched_date TEXT,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS watchlist (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    letterboxd_uri TEXT,
+    added_date TEXT,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    letterboxd_uri TEXT,
+    entry_uri TEXT,
+    review_date TEXT,
+    rating REAL,
+    rewatch BOOLEAN,
+    review TEXT,
+    tags TEXT,
+    watched_date TEXT,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS diary (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    letterboxd_uri TEXT,
+    entry_uri TEXT,
+    diary_date TEXT,
+    rating REAL,
+    rewatch BOOLEAN,
+    tags TEXT,
+    watched_date TEXT,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS ratings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    letterboxd_uri TEXT,
+    rating_date TEXT,
+    rating REAL,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    letterboxd_uri TEXT,
+    entry_uri TEXT,
+    comment_date TEXT,
+    comment TEXT,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS likes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT,
+    letterboxd_uri TEXT,
+    target_uri TEXT,
+    name TEXT,
+    liked_date TEXT,
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS lists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uri TEXT UNIQUE,
+    name TEXT,
+    description TEXT,
+    tags TEXT,
+    created_date TEXT
+);
+
+CREATE TABLE IF NOT EXISTS list_entries (
+    list_id INTEGER,
+    position INTEGER,
+    letterboxd_uri TEXT,
+    entry_uri TEXT,
+    note TEXT,
+    PRIMARY KEY(list_id, position),
+    FOREIGN KEY(list_id) REFERENCES lists(id),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE TABLE IF NOT EXISTS import_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at TEXT,
+    finished_at TEXT,
+    status TEXT,
+    source TEXT
+);
+
+CREATE TABLE IF NOT EXISTS import_run_files (
+    run_id INTEGER,
+    file TEXT,
+    added INTEGER,
+    updated INTEGER,
+    skipped INTEGER,
+    PRIMARY KEY(run_id, file),
+    FOREIGN KEY(run_id) REFERENCES import_runs(id)
+);
+
+CREATE TABLE IF NOT EXISTS import_run_errors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER,
+    file TEXT,
+    line INTEGER,
+    column_name TEXT,
+    problem TEXT,
+    action TEXT,
+    message TEXT,
+    FOREIGN KEY(run_id) REFERENCES import_runs(id)
+);
+
+CREATE TABLE IF NOT EXISTS film_uris (
+    uri TEXT PRIMARY KEY,
+    film_uri TEXT NOT NULL,
+    name TEXT,
+    year INTEGER,
+    method TEXT,
+    FOREIGN KEY(film_uri) REFERENCES movies(letterboxd_uri)
+);
+
+CREATE INDEX IF NOT EXISTS idx_film_uris_name_year ON film_uris (name, year);
+
+-- Clés naturelles des tables filles : réimporter un export ne crée pas de doublons. Les
+-- critiques et commentaires sont identifiés par leur URI d'entrée, leur letterboxd_uri
+-- étant le film canonique.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_watched_natural_key ON watched (letterboxd_uri, watched_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_watchlist_natural_key ON watchlist (letterboxd_uri, added_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ratings_natural_key ON ratings (letterboxd_uri, rating_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_diary_entry_key ON diary (entry_uri, diary_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_entry_key ON reviews (entry_uri, review_date);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_comments_entry_key ON comments (entry_uri, comment_date, comment);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_natural_key ON likes (kind, target_uri);
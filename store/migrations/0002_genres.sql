@@ -0,0 +1,16 @@
+-- Genres TMDB des films, renseignés par l'import du JSON produit par tmdb_call.go.
+
+CREATE TABLE genres (
+    id INTEGER PRIMARY KEY, -- Identifiant TMDB du genre
+    name TEXT NOT NULL
+);
+
+CREATE TABLE movie_genres (
+    letterboxd_uri TEXT,
+    genre_id INTEGER,
+    PRIMARY KEY(letterboxd_uri, genre_id),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(genre_id) REFERENCES genres(id)
+);
+
+CREATE INDEX idx_movie_genres_genre ON movie_genres (genre_id);
@@ -0,0 +1,18 @@
+-- Pays de production TMDB des films, identifiés par leur code ISO 3166-1. Le pays principal
+-- est le premier de la liste TMDB ; les autres sont des coproducteurs.
+
+CREATE TABLE countries (
+    iso_3166_1 TEXT PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE movie_countries (
+    letterboxd_uri TEXT,
+    iso_3166_1 TEXT,
+    is_primary BOOLEAN NOT NULL DEFAULT 0,
+    PRIMARY KEY(letterboxd_uri, iso_3166_1),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(iso_3166_1) REFERENCES countries(iso_3166_1)
+);
+
+CREATE INDEX idx_movie_countries_country ON movie_countries (iso_3166_1);
@@ -0,0 +1,35 @@
+-- Générique TMDB des films : personnes, distribution (ordre d'affiche et rôle) et équipe
+-- technique (poste et département). Un crédit est identifié par son credit_id TMDB.
+
+CREATE TABLE people (
+    id INTEGER PRIMARY KEY, -- Identifiant TMDB de la personne
+    name TEXT NOT NULL,
+    profile_path TEXT,
+    known_for_department TEXT
+);
+
+CREATE TABLE movie_cast (
+    letterboxd_uri TEXT,
+    credit_id TEXT,
+    person_id INTEGER NOT NULL,
+    character TEXT,
+    billing_order INTEGER,
+    PRIMARY KEY(letterboxd_uri, credit_id),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(person_id) REFERENCES people(id)
+);
+
+CREATE INDEX idx_movie_cast_person ON movie_cast (person_id);
+
+CREATE TABLE movie_crew (
+    letterboxd_uri TEXT,
+    credit_id TEXT,
+    person_id INTEGER NOT NULL,
+    job TEXT,
+    department TEXT,
+    PRIMARY KEY(letterboxd_uri, credit_id),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(person_id) REFERENCES people(id)
+);
+
+CREATE INDEX idx_movie_crew_person ON movie_crew (person_id, job);
@@ -0,0 +1,27 @@
+-- Filmographies TMDB des personnes (filmographies.json, produit par tmdb_call.go
+-- -filmographies), comparées aux films vus grâce à l'identifiant TMDB des films.
+
+ALTER TABLE movies ADD COLUMN tmdb_id INTEGER;
+
+CREATE INDEX idx_movies_tmdb_id ON movies (tmdb_id);
+
+CREATE TABLE person_filmography (
+    credit_id TEXT PRIMARY KEY,
+    person_id INTEGER NOT NULL,
+    tmdb_id INTEGER NOT NULL, -- Identifiant TMDB du film
+    title TEXT,
+    release_date TEXT,
+    kind TEXT NOT NULL, -- cast ou crew
+    job TEXT, -- Poste pour l'équipe technique, vide pour la distribution
+    character TEXT,
+    runtime INTEGER,
+    video BOOLEAN NOT NULL DEFAULT 0,
+    tv_movie BOOLEAN NOT NULL DEFAULT 0,
+    uncredited BOOLEAN NOT NULL DEFAULT 0,
+    FOREIGN KEY(person_id) REFERENCES people(id)
+);
+
+CREATE INDEX idx_person_filmography_person ON person_filmography (person_id, kind, job);
+
+-- Date de récupération de la filmographie, vide si elle n'a jamais été récupérée
+ALTER TABLE people ADD COLUMN filmography_fetched_at TEXT;
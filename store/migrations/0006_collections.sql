@@ -0,0 +1,22 @@
+-- Collections TMDB (sagas) et leurs films dans l'ordre de sortie (collections.json, produit
+-- par tmdb_call.go), comparés aux films vus grâce à l'identifiant TMDB des films.
+
+CREATE TABLE collections (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    poster_path TEXT,
+    fetched_at TEXT
+);
+
+CREATE TABLE collection_parts (
+    collection_id INTEGER NOT NULL,
+    tmdb_id INTEGER NOT NULL, -- Identifiant TMDB du film
+    position INTEGER NOT NULL, -- Rang dans l'ordre de sortie, à partir de 0
+    title TEXT,
+    release_date TEXT,
+    poster_path TEXT,
+    PRIMARY KEY(collection_id, tmdb_id),
+    FOREIGN KEY(collection_id) REFERENCES collections(id)
+);
+
+CREATE INDEX idx_collection_parts_tmdb_id ON collection_parts (tmdb_id);
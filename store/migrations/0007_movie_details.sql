@@ -0,0 +1,54 @@
+-- Détails TMDB des films jusqu'ici ignorés : budget, recettes et identifiant IMDb, mots-clés,
+-- sociétés de production et langues parlées.
+
+ALTER TABLE movies ADD COLUMN budget INTEGER;
+ALTER TABLE movies ADD COLUMN revenue INTEGER;
+ALTER TABLE movies ADD COLUMN imdb_id TEXT;
+
+CREATE TABLE keywords (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE movie_keywords (
+    letterboxd_uri TEXT,
+    keyword_id INTEGER,
+    PRIMARY KEY(letterboxd_uri, keyword_id),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(keyword_id) REFERENCES keywords(id)
+);
+
+CREATE INDEX idx_movie_keywords_keyword ON movie_keywords (keyword_id);
+
+CREATE TABLE companies (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    logo_path TEXT,
+    origin_country TEXT
+);
+
+CREATE TABLE movie_companies (
+    letterboxd_uri TEXT,
+    company_id INTEGER,
+    PRIMARY KEY(letterboxd_uri, company_id),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(company_id) REFERENCES companies(id)
+);
+
+CREATE INDEX idx_movie_companies_company ON movie_companies (company_id);
+
+CREATE TABLE languages (
+    iso_639_1 TEXT PRIMARY KEY,
+    english_name TEXT,
+    name TEXT
+);
+
+CREATE TABLE movie_spoken_languages (
+    letterboxd_uri TEXT,
+    iso_639_1 TEXT,
+    PRIMARY KEY(letterboxd_uri, iso_639_1),
+    FOREIGN KEY(letterboxd_uri) REFERENCES movies(letterboxd_uri),
+    FOREIGN KEY(iso_639_1) REFERENCES languages(iso_639_1)
+);
+
+CREATE INDEX idx_movie_spoken_languages_language ON movie_spoken_languages (iso_639_1);
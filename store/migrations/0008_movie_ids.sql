@@ -0,0 +1,18 @@
+-- Identifiants TMDB et IMDb uniques : un même film ne peut pas être enregistré sous deux
+-- URI Letterboxd. Les doublons laissés par les imports précédents sont effacés, le plus
+-- ancien film gardant l'identifiant ; le prochain import fusionne les deux URI.
+
+UPDATE movies SET imdb_id = NULL WHERE imdb_id = '';
+
+UPDATE movies SET tmdb_id = NULL
+WHERE tmdb_id IS NOT NULL
+    AND rowid NOT IN (SELECT MIN(rowid) FROM movies WHERE tmdb_id IS NOT NULL GROUP BY tmdb_id);
+
+UPDATE movies SET imdb_id = NULL
+WHERE imdb_id IS NOT NULL
+    AND rowid NOT IN (SELECT MIN(rowid) FROM movies WHERE imdb_id IS NOT NULL GROUP BY imdb_id);
+
+DROP INDEX idx_movies_tmdb_id;
+
+CREATE UNIQUE INDEX idx_movies_tmdb_id ON movies (tmdb_id);
+CREATE UNIQUE INDEX idx_movies_imdb_id ON movies (imdb_id);
@@ -0,0 +1,8 @@
+-- tmdb_call.go écrit désormais directement dans la base : la note de la correspondance
+-- trouvée par la recherche TMDB et la collection du film, jusqu'ici dans output.json, sont
+-- conservées avec le film.
+
+ALTER TABLE movies ADD COLUMN match_score REAL;
+ALTER TABLE movies ADD COLUMN collection_id INTEGER;
+
+CREATE INDEX idx_movies_collection_id ON movies (collection_id);
@@ -0,0 +1,5 @@
+-- Date de récupération des détails TMDB de chaque film, pour que tmdb_call.go -refresh ne
+-- récupère à nouveau que les films dont les données ont vieilli. Les films déjà en base
+-- n'en ont pas : ils sont considérés comme périmés.
+
+ALTER TABLE movies ADD COLUMN fetched_at TEXT;
@@ -0,0 +1,15 @@
+-- Seuls les films créés pour une entrée de journal (critique, commentaire, liste) peuvent
+-- être rattachés à un autre film par identifiant TMDB. Les films déjà connus qui n'ont
+-- aucune ligne dans watched, watchlist, ratings ou les films aimés sont marqués comme tels.
+
+UPDATE film_uris SET method = 'entry'
+WHERE uri = film_uri
+    AND uri NOT IN (SELECT letterboxd_uri FROM watched)
+    AND uri NOT IN (SELECT letterboxd_uri FROM watchlist)
+    AND uri NOT IN (SELECT letterboxd_uri FROM ratings)
+    AND uri NOT IN (SELECT target_uri FROM likes WHERE kind = 'film');
+
+-- Les fusions par identifiant TMDB ont pu rattacher l'un à l'autre deux films distincts
+-- (un mauvais identifiant suffit) : elles sont défaites, le prochain import recrée le film
+-- et ses lignes.
+DELETE FROM film_uris WHERE method = 'tmdb';
@@ -0,0 +1,15 @@
+-- Une note remplace la précédente sur Letterboxd : ratings.csv ne donne que la note actuelle
+-- de chaque film, avec sa date. Ne garder qu'une note par film, la plus récente, pour que
+-- les anciennes notes d'un film noté à nouveau ne faussent plus les moyennes.
+
+DELETE FROM ratings
+WHERE id NOT IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY letterboxd_uri ORDER BY rating_date DESC, id DESC) AS n
+        FROM ratings
+    )
+    WHERE n = 1
+);
+
+DROP INDEX IF EXISTS idx_ratings_natural_key;
+CREATE UNIQUE INDEX idx_ratings_film ON ratings (letterboxd_uri);
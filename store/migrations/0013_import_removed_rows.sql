@@ -0,0 +1,4 @@
+-- Nombre de lignes supprimées à l'import d'un fichier car absentes de l'export (films
+-- retirés de la watchlist, notes effacées).
+
+ALTER TABLE import_run_files ADD COLUMN removed INTEGER NOT NULL DEFAULT 0;
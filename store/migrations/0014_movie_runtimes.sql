@@ -0,0 +1,12 @@
+-- Durée des films TMDB des filmographies, récupérée une fois pour toutes par tmdb_call.go
+-- -filmographies pour distinguer les courts métrages (0 si TMDB ne la connaît pas). Les
+-- durées déjà connues des filmographies sont reprises ; les autres seront récupérées une
+-- dernière fois.
+
+CREATE TABLE movie_runtimes (
+    tmdb_id INTEGER PRIMARY KEY, -- Identifiant TMDB du film
+    runtime INTEGER NOT NULL
+);
+
+INSERT INTO movie_runtimes (tmdb_id, runtime)
+SELECT tmdb_id, MAX(runtime) FROM person_filmography WHERE runtime > 0 GROUP BY tmdb_id;
@@ -0,0 +1,13 @@
+-- Date de récupération de chaque groupe de champs d'un film, pour que tmdb_call.go -refresh
+-- ne rafraîchisse que les groupes périmés sans rajeunir les autres : votes (popularité,
+-- note, nombre de votes), sortie (statut, date de sortie, durée) et détails (tout le reste,
+-- générique, mots-clés et identifiants compris). Un film sans date de détails n'a pas été
+-- récupéré en entier par la version actuelle de tmdb_call.go. Les films récupérés depuis
+-- l'ajout de fetched_at l'ont été en entier.
+
+ALTER TABLE movies ADD COLUMN votes_fetched_at TEXT;
+ALTER TABLE movies ADD COLUMN release_fetched_at TEXT;
+ALTER TABLE movies ADD COLUMN details_fetched_at TEXT;
+
+UPDATE movies SET votes_fetched_at = fetched_at, release_fetched_at = fetched_at, details_fetched_at = fetched_at
+WHERE fetched_at IS NOT NULL;
@@ -0,0 +1,6 @@
+-- Identifiant TMDB d'un film enregistré sans le sien parce qu'il était déjà attribué à un
+-- autre film de watched, watchlist ou ratings. tmdb_id reste unique ; tmdb_call.go retrouve
+-- ainsi ces films dans movies.db et ne les recherche plus à chaque exécution. Les films déjà
+-- enregistrés sans identifiant seront recherchés une dernière fois.
+
+ALTER TABLE movies ADD COLUMN tmdb_conflict_id INTEGER;
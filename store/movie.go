@@ -0,0 +1,404 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ProductionCountry représente un pays de production d'un film TMDB.
+type ProductionCountry struct {
+	ISO3166_1 string `json:"iso_3166_1" db:"iso_3166_1"`
+	Name      string `json:"name" db:"name"`
+}
+
+// ExternalIDs représente les identifiants d'un film dans d'autres bases
+// (append_to_response=external_ids).
+type ExternalIDs struct {
+	IMDbID string `json:"imdb_id"`
+}
+
+// CollectionRef représente la collection (saga) d'un film dans ses détails TMDB.
+type CollectionRef struct {
+	ID           int    `json:"id" db:"id"`
+	Name         string `json:"name" db:"name"`
+	PosterPath   string `json:"poster_path" db:"poster_path"`
+	BackdropPath string `json:"backdrop_path" db:"-"`
+}
+
+// Movie représente la structure d'un film.
+type Movie struct {
+	LetterboxdURI            string  `json:"letterboxd_uri" db:"letterboxd_uri"` // Utilisé comme identifiant global
+	TMDBID                   int     `json:"tmdb_id" db:"tmdb_id"`
+	TMDBConflictID           int     `json:"tmdb_conflict_id,omitempty" db:"tmdb_conflict_id"` // Identifiant TMDB déjà attribué à un autre film (voir Result.TMDBConflict)
+	Title                    string  `json:"title" db:"title"`
+	OriginalTitle            string  `json:"original_title" db:"original_title"`
+	Overview                 string  `json:"overview" db:"overview"`
+	ReleaseDate              string  `json:"release_date" db:"release_date"`
+	PosterPath               string  `json:"poster_path" db:"poster_path"`
+	Popularity               float64 `json:"popularity" db:"popularity"`
+	VoteAverage              float64 `json:"vote_average" db:"vote_average"`
+	VoteCount                int     `json:"vote_count" db:"vote_count"`
+	Adult                    bool    `json:"adult" db:"adult"`
+	OriginalLanguage         string  `json:"original_language" db:"original_language"`
+	Runtime                  int     `json:"runtime" db:"runtime"`
+	Tagline                  string  `json:"tagline" db:"tagline"`
+	Status                   string  `json:"status" db:"status"`
+	Source                   string  `json:"source" db:"source"`
+	Year                     int     `json:"year" db:"year"`
+	MainProductionCountry    string  `json:"main_production_country" db:"main_production_country"`
+	OtherProductionCountries string  `json:"other_production_countries" db:"other_production_countries"`
+	Budget                   int64   `json:"budget" db:"budget"`
+	Revenue                  int64   `json:"revenue" db:"revenue"`
+	IMDbID                   string  `json:"imdb_id" db:"imdb_id"`
+	MatchScore               float64 `json:"match_score" db:"match_score"` // 1 pour un film fixé dans overrides.json
+	CollectionID             int     `json:"collection_id" db:"collection_id"`
+	FetchedAt                string  `json:"fetched_at" db:"fetched_at"` // Date de la dernière récupération TMDB (RFC 3339)
+	// Date de récupération de chaque groupe de champs, rafraîchis séparément par tmdb_call.go
+	// -refresh. DetailsFetchedAt est vide tant que le film n'a pas été récupéré en entier.
+	VotesFetchedAt   string `json:"votes_fetched_at" db:"votes_fetched_at"`
+	ReleaseFetchedAt string `json:"release_fetched_at" db:"release_fetched_at"`
+	DetailsFetchedAt string `json:"details_fetched_at" db:"details_fetched_at"`
+	// Champs temporaires pour l'import JSON
+	ProductionCountries []ProductionCountry `json:"production_countries" db:"-"`
+	Genres              []Genre             `json:"genres" db:"-"`
+	Credits             *Credits            `json:"credits" db:"-"`
+	Keywords            *Keywords           `json:"keywords" db:"-"`
+	ExternalIDs         *ExternalIDs        `json:"external_ids" db:"-"`
+	ProductionCompanies []ProductionCompany `json:"production_companies" db:"-"`
+	SpokenLanguages     []SpokenLanguage    `json:"spoken_languages" db:"-"`
+	BelongsToCollection *CollectionRef      `json:"belongs_to_collection" db:"-"`
+}
+
+// MovieColumns sélectionne les colonnes de Movie dans la table movies (alias m). Les films
+// créés à partir des seuls CSV n'ont pas encore de données TMDB, d'où les IFNULL.
+const MovieColumns = `m.letterboxd_uri, IFNULL(m.tmdb_id, 0) AS tmdb_id, IFNULL(m.tmdb_conflict_id, 0) AS tmdb_conflict_id,
+	IFNULL(m.title, '') AS title, IFNULL(m.original_title, '') AS original_title,
+	IFNULL(m.overview, '') AS overview, IFNULL(m.release_date, '') AS release_date,
+	IFNULL(m.poster_path, '') AS poster_path, IFNULL(m.popularity, 0) AS popularity,
+	IFNULL(m.vote_average, 0) AS vote_average, IFNULL(m.vote_count, 0) AS vote_count,
+	IFNULL(m.adult, 0) AS adult, IFNULL(m.original_language, '') AS original_language,
+	IFNULL(m.runtime, 0) AS runtime, IFNULL(m.tagline, '') AS tagline, IFNULL(m.status, '') AS status,
+	IFNULL(m.source, '') AS source, IFNULL(m.year, 0) AS year,
+	IFNULL(m.main_production_country, '') AS main_production_country,
+	IFNULL(m.other_production_countries, '') AS other_production_countries,
+	IFNULL(m.budget, 0) AS budget, IFNULL(m.revenue, 0) AS revenue, IFNULL(m.imdb_id, '') AS imdb_id,
+	IFNULL(m.match_score, 0) AS match_score, IFNULL(m.collection_id, 0) AS collection_id,
+	IFNULL(m.fetched_at, '') AS fetched_at, IFNULL(m.votes_fetched_at, '') AS votes_fetched_at,
+	IFNULL(m.release_fetched_at, '') AS release_fetched_at, IFNULL(m.details_fetched_at, '') AS details_fetched_at`
+
+// aliasURIs sélectionne les URI rattachées à un autre film que le leur.
+const aliasURIs = `SELECT uri FROM film_uris WHERE uri != film_uri`
+
+// Result décrit l'enregistrement d'un film par SaveMovie.
+type Result struct {
+	Added   bool // Le film n'était pas encore en base
+	Changed bool // Le film ou ses données associées ont été réécrits
+	// MergedInto est le film déjà enregistré sous le même identifiant TMDB auquel l'URI,
+	// créée pour une entrée de journal, a été rattachée ; rien d'autre n'a été écrit
+	MergedInto string
+	// TMDBConflict est le film auquel l'identifiant TMDB était déjà attribué alors que les
+	// deux URI sont des films distincts : le film a été enregistré sans, l'identifiant étant
+	// conservé dans TMDBConflictID
+	TMDBConflict string
+	// IMDbConflict est le film auquel l'identifiant IMDb était déjà attribué : le film a
+	// été enregistré sans
+	IMDbConflict string
+}
+
+// SaveMovie insère ou met à jour un film TMDB et ses genres, pays, générique, mots-clés,
+// studios et langues, en ne réécrivant que ce qui a changé. L'identifiant TMDB sert à
+// rattacher à son film une URI créée pour une entrée de journal (critique, liste...) ;
+// deux films distincts de watched, watchlist ou ratings ne sont jamais fusionnés, le second
+// est enregistré avec son identifiant TMDB dans TMDBConflictID.
+func SaveMovie(db DBTX, m Movie) (Result, error) {
+	var res Result
+	var err error
+	m.LetterboxdURI, err = canonicalFilmURI(db, m.LetterboxdURI)
+	if err != nil {
+		return res, fmt.Errorf("résolution du film: %w", err)
+	}
+
+	// Un identifiant en conflit est réexaminé : l'autre film a pu être supprimé depuis
+	if m.TMDBID == 0 {
+		m.TMDBID = m.TMDBConflictID
+	}
+	m.TMDBConflictID = 0
+	if m.TMDBID != 0 {
+		var first string
+		err := db.Get(&first, `SELECT letterboxd_uri FROM movies WHERE tmdb_id = ? AND letterboxd_uri != ?
+			AND letterboxd_uri NOT IN (`+aliasURIs+`)`, m.TMDBID, m.LetterboxdURI)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return res, fmt.Errorf("recherche du film: %w", err)
+		default:
+			entry, err := isEntryFilm(db, m.LetterboxdURI)
+			if err != nil {
+				return res, fmt.Errorf("recherche du film: %w", err)
+			}
+			if entry {
+				res.MergedInto = first
+				if err := mergeFilm(db, m.LetterboxdURI, first, "tmdb"); err != nil {
+					return res, fmt.Errorf("fusion du film: %w", err)
+				}
+				return res, nil
+			}
+			res.TMDBConflict = first
+			m.TMDBID, m.TMDBConflictID = 0, m.TMDBID
+		}
+	}
+
+	// L'identifiant IMDb vient des détails TMDB ou, à défaut, de external_ids
+	if m.IMDbID == "" && m.ExternalIDs != nil {
+		m.IMDbID = m.ExternalIDs.IMDbID
+	}
+	if m.IMDbID != "" {
+		var other string
+		err := db.Get(&other, `SELECT letterboxd_uri FROM movies WHERE imdb_id = ? AND letterboxd_uri != ?
+			AND letterboxd_uri NOT IN (`+aliasURIs+`)`, m.IMDbID, m.LetterboxdURI)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return res, fmt.Errorf("recherche du film: %w", err)
+		default:
+			res.IMDbConflict = other
+			m.IMDbID = ""
+		}
+	}
+
+	// Extraire le pays principal et les autres pays à partir du tableau ProductionCountries
+	var mainCountry string
+	var otherCountries []string
+	for i, pc := range m.ProductionCountries {
+		if i == 0 {
+			mainCountry = pc.Name
+		} else {
+			otherCountries = append(otherCountries, pc.Name)
+		}
+	}
+	m.MainProductionCountry = mainCountry
+	m.OtherProductionCountries = strings.Join(otherCountries, ", ")
+	if m.BelongsToCollection != nil {
+		m.CollectionID = m.BelongsToCollection.ID
+	}
+
+	// Une date de récupération sans date par groupe (JSON d'une version précédente) est celle
+	// d'une récupération complète
+	for _, at := range []*string{&m.VotesFetchedAt, &m.ReleaseFetchedAt, &m.DetailsFetchedAt} {
+		if *at == "" {
+			*at = m.FetchedAt
+		}
+	}
+
+	// Comparer avec la version en base pour ne réécrire que les films modifiés
+	var existing Movie
+	err = db.Get(&existing, `SELECT `+MovieColumns+` FROM movies m WHERE m.letterboxd_uri = ?`, m.LetterboxdURI)
+	switch {
+	case err == sql.ErrNoRows:
+		res.Added = true
+	case err != nil:
+		return res, fmt.Errorf("lecture du film: %w", err)
+	default:
+		// Un JSON sans date de récupération ne rajeunit ni ne vieillit le film
+		for _, at := range []struct{ new, old *string }{
+			{&m.FetchedAt, &existing.FetchedAt},
+			{&m.VotesFetchedAt, &existing.VotesFetchedAt},
+			{&m.ReleaseFetchedAt, &existing.ReleaseFetchedAt},
+			{&m.DetailsFetchedAt, &existing.DetailsFetchedAt},
+		} {
+			if *at.new == "" {
+				*at.new = *at.old
+			}
+		}
+		candidate := m
+		candidate.ProductionCountries = nil
+		candidate.Genres = nil
+		candidate.Credits = nil
+		candidate.Keywords = nil
+		candidate.ProductionCompanies = nil
+		candidate.SpokenLanguages = nil
+		candidate.ExternalIDs = nil
+		candidate.BelongsToCollection = nil
+		res.Changed = !reflect.DeepEqual(existing, candidate)
+	}
+
+	if res.Added || res.Changed {
+		_, err = db.NamedExec(`INSERT OR REPLACE INTO movies
+			(letterboxd_uri, title, original_title, overview, release_date, poster_path,
+			popularity, vote_average, vote_count, adult, original_language, runtime,
+			tagline, status, source, year, main_production_country, other_production_countries,
+			budget, revenue, tmdb_id, tmdb_conflict_id, imdb_id, match_score, collection_id, fetched_at,
+			votes_fetched_at, release_fetched_at, details_fetched_at)
+			VALUES (:letterboxd_uri, :title, :original_title, :overview, :release_date, :poster_path,
+			:popularity, :vote_average, :vote_count, :adult, :original_language, :runtime,
+			:tagline, :status, :source, :year, :main_production_country, :other_production_countries,
+			:budget, :revenue, NULLIF(:tmdb_id, 0), NULLIF(:tmdb_conflict_id, 0), NULLIF(:imdb_id, ''), NULLIF(:match_score, 0),
+			NULLIF(:collection_id, 0), NULLIF(:fetched_at, ''), NULLIF(:votes_fetched_at, ''),
+			NULLIF(:release_fetched_at, ''), NULLIF(:details_fetched_at, ''))`, m)
+		if err != nil {
+			return res, fmt.Errorf("insertion/mise à jour du film: %w", err)
+		}
+	}
+
+	syncs := []struct {
+		what string
+		sync func() (bool, error)
+	}{
+		{"genres", func() (bool, error) { return syncMovieGenres(db, m.LetterboxdURI, m.Genres) }},
+		{"pays", func() (bool, error) { return syncMovieCountries(db, m.LetterboxdURI, m.ProductionCountries) }},
+		{"générique", func() (bool, error) { return syncMovieCredits(db, m.LetterboxdURI, m.Credits) }},
+		{"mots-clés", func() (bool, error) { return syncMovieKeywords(db, m.LetterboxdURI, m.Keywords) }},
+		{"studios", func() (bool, error) { return syncMovieCompanies(db, m.LetterboxdURI, m.ProductionCompanies) }},
+		{"langues", func() (bool, error) { return syncMovieLanguages(db, m.LetterboxdURI, m.SpokenLanguages) }},
+	}
+	for _, s := range syncs {
+		changed, err := s.sync()
+		if err != nil {
+			return res, fmt.Errorf("%s du film: %w", s.what, err)
+		}
+		res.Changed = res.Changed || changed
+	}
+	return res, nil
+}
+
+// LoadMovies lit les films enregistrés avec leurs données TMDB, c'est-à-dire ceux qui ont
+// un identifiant TMDB, même en conflit avec un autre film, et leurs détails. Credits reste nil pour un film dont le générique
+// n'a jamais été récupéré.
+func LoadMovies(db DBTX) ([]Movie, error) {
+	var movies []Movie
+	if err := db.Select(&movies, `SELECT `+MovieColumns+` FROM movies m
+		WHERE (m.tmdb_id IS NOT NULL OR m.tmdb_conflict_id IS NOT NULL) AND m.letterboxd_uri NOT IN (`+aliasURIs+`)
+		ORDER BY m.letterboxd_uri`); err != nil {
+		return nil, err
+	}
+	var collections []CollectionRef
+	if err := db.Select(&collections, `SELECT id, name, IFNULL(poster_path, '') AS poster_path FROM collections`); err != nil {
+		return nil, err
+	}
+	names := make(map[int]CollectionRef, len(collections))
+	for _, c := range collections {
+		names[c.ID] = c
+	}
+
+	for i := range movies {
+		m := &movies[i]
+		if err := loadMovieDetails(db, m); err != nil {
+			return nil, fmt.Errorf("détails du film %s: %w", m.Title, err)
+		}
+		if m.CollectionID != 0 {
+			ref, ok := names[m.CollectionID]
+			if !ok {
+				ref = CollectionRef{ID: m.CollectionID}
+			}
+			m.BelongsToCollection = &ref
+		}
+	}
+	return movies, nil
+}
+
+// loadMovieDetails lit les genres, pays, générique, mots-clés, studios et langues d'un film.
+func loadMovieDetails(db DBTX, m *Movie) error {
+	uri := m.LetterboxdURI
+	if err := db.Select(&m.Genres, `SELECT g.id, g.name FROM movie_genres mg JOIN genres g ON g.id = mg.genre_id
+		WHERE mg.letterboxd_uri = ? ORDER BY g.id`, uri); err != nil {
+		return err
+	}
+	if err := db.Select(&m.ProductionCountries, `SELECT c.iso_3166_1, c.name FROM movie_countries mc
+		JOIN countries c ON c.iso_3166_1 = mc.iso_3166_1
+		WHERE mc.letterboxd_uri = ? ORDER BY mc.is_primary DESC, c.iso_3166_1`, uri); err != nil {
+		return err
+	}
+
+	var credits Credits
+	if err := db.Select(&credits.Cast, `SELECT c.credit_id, IFNULL(c.character, '') AS character,
+		IFNULL(c.billing_order, 0) AS billing_order, p.id, p.name, IFNULL(p.profile_path, '') AS profile_path,
+		IFNULL(p.known_for_department, '') AS known_for_department
+		FROM movie_cast c JOIN people p ON p.id = c.person_id
+		WHERE c.letterboxd_uri = ? ORDER BY billing_order, c.credit_id`, uri); err != nil {
+		return err
+	}
+	if err := db.Select(&credits.Crew, `SELECT c.credit_id, IFNULL(c.job, '') AS job,
+		IFNULL(c.department, '') AS department, p.id, p.name, IFNULL(p.profile_path, '') AS profile_path,
+		IFNULL(p.known_for_department, '') AS known_for_department
+		FROM movie_crew c JOIN people p ON p.id = c.person_id
+		WHERE c.letterboxd_uri = ? ORDER BY c.credit_id`, uri); err != nil {
+		return err
+	}
+	if len(credits.Cast) > 0 || len(credits.Crew) > 0 {
+		m.Credits = &credits
+	}
+
+	m.Keywords = &Keywords{}
+	if err := db.Select(&m.Keywords.Keywords, `SELECT k.id, k.name FROM movie_keywords mk
+		JOIN keywords k ON k.id = mk.keyword_id WHERE mk.letterboxd_uri = ? ORDER BY k.id`, uri); err != nil {
+		return err
+	}
+	if err := db.Select(&m.ProductionCompanies, `SELECT c.id, c.name, IFNULL(c.logo_path, '') AS logo_path,
+		IFNULL(c.origin_country, '') AS origin_country FROM movie_companies mc
+		JOIN companies c ON c.id = mc.company_id WHERE mc.letterboxd_uri = ? ORDER BY c.id`, uri); err != nil {
+		return err
+	}
+	if err := db.Select(&m.SpokenLanguages, `SELECT l.iso_639_1, IFNULL(l.english_name, '') AS english_name,
+		IFNULL(l.name, '') AS name FROM movie_spoken_languages ml
+		JOIN languages l ON l.iso_639_1 = ml.iso_639_1 WHERE ml.letterboxd_uri = ? ORDER BY l.iso_639_1`, uri); err != nil {
+		return err
+	}
+	m.ExternalIDs = &ExternalIDs{IMDbID: m.IMDbID}
+	return nil
+}
+
+// canonicalFilmURI renvoie le film canonique associé à une URI, ou l'URI elle-même si elle
+// n'a pas encore été résolue.
+func canonicalFilmURI(db DBTX, uri string) (string, error) {
+	var filmURI string
+	err := db.Get(&filmURI, `SELECT film_uri FROM film_uris WHERE uri = ?`, uri)
+	if err == sql.ErrNoRows {
+		return uri, nil
+	}
+	return filmURI, err
+}
+
+// DetachFilm défait le rattachement d'une URI à un autre film, par exemple pour appliquer
+// un identifiant TMDB fixé à la main : l'URI redevient son propre film, recréé au besoin
+// avec le nom et l'année connus. Elle renvoie le film auquel l'URI était rattachée, ou ""
+// si elle ne l'était pas. Les lignes déplacées vers ce film y restent jusqu'au prochain
+// import de l'export.
+func DetachFilm(db DBTX, uri string) (string, error) {
+	var into string
+	err := db.Get(&into, `SELECT film_uri FROM film_uris WHERE uri = ? AND film_uri != uri`, uri)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.Exec(`UPDATE film_uris SET film_uri = uri, method = 'film' WHERE uri = ?`, uri); err != nil {
+		return "", err
+	}
+	if _, err := db.Exec(`INSERT OR IGNORE INTO movies (letterboxd_uri, title, year)
+		SELECT uri, name, year FROM film_uris WHERE uri = ?`, uri); err != nil {
+		return "", err
+	}
+	return into, nil
+}
+
+// isEntryFilm indique si le film n'est connu que par des entrées de journal, critiques,
+// commentaires ou listes, et pas par watched, watchlist ou ratings.
+func isEntryFilm(db DBTX, uri string) (bool, error) {
+	var entry bool
+	err := db.Get(&entry, `SELECT EXISTS (SELECT 1 FROM film_uris WHERE uri = ? AND film_uri = uri AND method = 'entry')`, uri)
+	return entry, err
+}
+
+// mergeFilm rattache le film from au film into (même identifiant TMDB par exemple).
+// Les lignes des tables filles sont déplacées par le prochain import.
+func mergeFilm(db DBTX, from, into, method string) error {
+	if _, err := db.Exec(`UPDATE film_uris SET film_uri = ? WHERE film_uri = ?`, into, from); err != nil {
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO film_uris (uri, film_uri, method) VALUES (?, ?, ?)
+		ON CONFLICT (uri) DO UPDATE SET film_uri = excluded.film_uri, method = excluded.method`, from, into, method)
+	return err
+}
@@ -0,0 +1,25 @@
+// Package store regroupe l'accès à movies.db partagé par le serveur et par tmdb_call.go :
+// ouverture de la base, migrations du schéma et enregistrement des films TMDB.
+package store
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DBTX regroupe les méthodes communes à *sqlx.DB et *sqlx.Tx utilisées pour écrire dans
+// la base, le plus souvent dans une transaction.
+type DBTX interface {
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+}
+
+// Open ouvre (ou crée) la base SQLite. Le mode WAL permet de continuer à servir les
+// lectures pendant qu'un import ou tmdb_call.go écrit dans sa transaction.
+func Open(path string) (*sqlx.DB, error) {
+	return sqlx.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+	"github.com/monsieurr/letterboxd_stats_viewer/tmdbapi"
+)
+
+// getCollection fetches a collection and its parts, sorted by release date. Unreleased
+// parts without a date come last.
+func getCollection(ctx context.Context, api tmdbapi.API, id int) (store.Collection, error) {
+	fetched, err := api.Collection(ctx, id)
+	if err != nil {
+		return store.Collection{}, err
+	}
+	parts := slices.Clone(fetched.Parts)
+	sort.SliceStable(parts, func(i, j int) bool {
+		a, b := parts[i].ReleaseDate, parts[j].ReleaseDate
+		if a == "" || b == "" {
+			return a != "" && b == ""
+		}
+		return a < b
+	})
+
+	c := store.Collection{ID: fetched.ID, Name: fetched.Name, PosterPath: fetched.PosterPath,
+		FetchedAt: time.Now().Format(time.RFC3339)}
+	for _, p := range parts {
+		c.Parts = append(c.Parts, store.CollectionPart{TMDBID: p.ID, Title: p.Title, ReleaseDate: p.ReleaseDate,
+			PosterPath: p.PosterPath})
+	}
+	return c, nil
+}
+
+// updateCollections fetches the collections of the movies that are not in movies.db yet, or
+// were fetched more than ttl ago, and writes each one in its own transaction.
+func updateCollections(ctx context.Context, api tmdbapi.API, db *sqlx.DB, movies []MovieDetails, ttl time.Duration) error {
+	known, err := store.CollectionFetchTimes(db)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	missing := make(map[int]string)
+	stale := 0
+	for _, movie := range movies {
+		ref := movie.BelongsToCollection
+		if ref == nil {
+			continue
+		}
+		if _, queued := missing[ref.ID]; queued {
+			continue
+		}
+		fetchedAt, ok := known[ref.ID]
+		switch {
+		case !ok:
+			missing[ref.ID] = ref.Name
+		case age(fetchedAt, now) > ttl:
+			missing[ref.ID] = ref.Name
+			stale++
+		}
+	}
+	log.Printf("Collections: %d in the database, fetching %d (%d stale)", len(known), len(missing), stale)
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		semaphore  = make(chan struct{}, maxConcurrentReqs)
+		savedCount int
+		errorCount int
+	)
+	for id, name := range missing {
+		if !acquire(ctx, semaphore) {
+			break
+		}
+		wg.Add(1)
+		go func(id int, name string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			c, err := getCollection(ctx, api, id)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				err = inTx(db, func(tx *sqlx.Tx) error {
+					_, err := store.SaveCollection(tx, c)
+					return err
+				})
+			}
+			if err != nil {
+				log.Printf("Error getting collection %s (ID: %d): %v", name, id, err)
+				errorCount++
+				return
+			}
+			savedCount++
+		}(id, name)
+	}
+	wg.Wait()
+
+	log.Printf("Saved %d collections (%d errors)", savedCount, errorCount)
+	return nil
+}
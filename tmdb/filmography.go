@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+	"github.com/monsieurr/letterboxd_stats_viewer/tmdbapi"
+)
+
+// tvMovieGenreID is the TMDB genre of TV movies.
+const tvMovieGenreID = 10770
+
+// filmographyPeople selects whose filmography to fetch: the directors and the actors billed
+// before castDepth in the watched movies.
+func filmographyPeople(movies []MovieDetails, castDepth int) map[int]string {
+	people := make(map[int]string)
+	for _, movie := range movies {
+		if movie.Source != "watched" || movie.Credits == nil {
+			continue
+		}
+		for _, c := range movie.Credits.Crew {
+			if c.Job == "Director" {
+				people[c.ID] = c.Name
+			}
+		}
+		for _, c := range movie.Credits.Cast {
+			if c.Order < castDepth {
+				people[c.ID] = c.Name
+			}
+		}
+	}
+	return people
+}
+
+// getFilmography fetches the movie filmography of a person.
+func getFilmography(ctx context.Context, api tmdbapi.API, id int, name string) (store.Filmography, error) {
+	credits, err := api.PersonMovieCredits(ctx, id)
+	if err != nil {
+		return store.Filmography{}, err
+	}
+	f := store.Filmography{PersonID: id, Name: name, FetchedAt: time.Now().Format(time.RFC3339)}
+	for _, c := range credits.Cast {
+		f.Credits = append(f.Credits, store.FilmographyCredit{CreditID: c.CreditID, TMDBID: c.ID, Title: c.Title,
+			ReleaseDate: c.ReleaseDate, Kind: "cast", Character: c.Character, Video: c.Video,
+			TVMovie:    slices.Contains(c.GenreIDs, tvMovieGenreID),
+			Uncredited: strings.Contains(strings.ToLower(c.Character), "uncredited")})
+	}
+	for _, c := range credits.Crew {
+		f.Credits = append(f.Credits, store.FilmographyCredit{CreditID: c.CreditID, TMDBID: c.ID, Title: c.Title,
+			ReleaseDate: c.ReleaseDate, Kind: "crew", Job: c.Job, Video: c.Video,
+			TVMovie: slices.Contains(c.GenreIDs, tvMovieGenreID)})
+	}
+	return f, nil
+}
+
+// getRuntime fetches the runtime of a movie, 0 when TMDB does not know it.
+func getRuntime(ctx context.Context, api tmdbapi.API, id int) (int, error) {
+	details, err := api.Movie(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return details.Runtime, nil
+}
+
+// saveRuntime records the runtime of a movie and copies it to the filmographies, in one
+// transaction.
+func saveRuntime(db *sqlx.DB, id, runtime int) error {
+	return inTx(db, func(tx *sqlx.Tx) error { return store.SaveRuntime(tx, id, runtime) })
+}
+
+// updateFilmographies fetches the filmographies that are not in movies.db yet, or were
+// fetched more than ttl ago, and writes each one in its own transaction. The runtime of
+// their movies, needed to tell shorts apart, is taken from movies.db when it is known there
+// and fetched once otherwise.
+func updateFilmographies(ctx context.Context, api tmdbapi.API, db *sqlx.DB, movies []MovieDetails, castDepth int, ttl time.Duration) error {
+	fetched, err := store.FilmographyFetchTimes(db)
+	if err != nil {
+		return err
+	}
+	runtimes, err := store.Runtimes(db)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	people := filmographyPeople(movies, castDepth)
+	stale := 0
+	for id := range people {
+		fetchedAt, ok := fetched[id]
+		switch {
+		case !ok:
+		case age(fetchedAt, now) > ttl:
+			stale++
+		default:
+			delete(people, id)
+		}
+	}
+	log.Printf("Filmographies: %d already in the database, fetching %d (%d stale)", len(fetched), len(people), stale)
+
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		semaphore  = make(chan struct{}, maxConcurrentReqs)
+		savedCount int
+		errorCount int
+	)
+	for id, name := range people {
+		if !acquire(ctx, semaphore) {
+			break
+		}
+		wg.Add(1)
+		go func(id int, name string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			f, err := getFilmography(ctx, api, id, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				for i := range f.Credits {
+					f.Credits[i].Runtime = runtimes[f.Credits[i].TMDBID]
+				}
+				err = inTx(db, func(tx *sqlx.Tx) error {
+					_, err := store.SaveFilmography(tx, f)
+					return err
+				})
+			}
+			if err != nil {
+				log.Printf("Error getting filmography of %s (ID: %d): %v", name, id, err)
+				errorCount++
+				return
+			}
+			savedCount++
+			log.Printf("Fetched filmography of %s: %d credits", name, len(f.Credits))
+		}(id, name)
+	}
+	wg.Wait()
+	log.Printf("Saved %d filmographies (%d errors)", savedCount, errorCount)
+
+	// Runtimes not recorded yet: an interrupted run leaves them for the next one
+	missing, err := store.MissingRuntimes(db)
+	if err != nil {
+		return err
+	}
+	var toFetch []int
+	for _, id := range missing {
+		runtime, ok := runtimes[id]
+		if !ok {
+			toFetch = append(toFetch, id)
+			continue
+		}
+		if err := saveRuntime(db, id, runtime); err != nil {
+			return err
+		}
+	}
+	log.Printf("Fetching runtime of %d movies (%d known from movies.db)", len(toFetch), len(missing)-len(toFetch))
+	errorCount = 0
+	for _, id := range toFetch {
+		if !acquire(ctx, semaphore) {
+			break
+		}
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			runtime, err := getRuntime(ctx, api, id)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				err = saveRuntime(db, id, runtime)
+			}
+			if err != nil {
+				log.Printf("Error getting runtime of movie %d: %v", id, err)
+				errorCount++
+			}
+		}(id)
+	}
+	wg.Wait()
+	if errorCount > 0 {
+		log.Printf("%d runtimes could not be fetched, run again to retry", errorCount)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const refreshReportFile = "refresh.json"
+
+// Field groups of a movie, each with its own TTL and fetch time. Every group comes from the
+// same movie details request: a movie is fetched again as soon as one of its groups is
+// stale, but only its stale groups are updated and get a new fetch time.
+const (
+	groupVotes   = "votes"   // Popularity, vote average and vote count, which drift every day
+	groupRelease = "release" // Status, release date and runtime, while the movie is unreleased or incomplete
+	groupDetails = "details" // Everything else: titles, credits, keywords, studios, ids...
+)
+
+// Groups of the data fetched besides the movies, each fetched again whole once older than
+// its TTL.
+const (
+	groupCollections   = "collections"   // Collections and their parts, which grow as sequels are announced
+	groupFilmographies = "filmographies" // Filmographies of directors and actors, which grow with each new film
+)
+
+// defaultTTLs are the TTLs used for the groups not set with -ttl.
+var defaultTTLs = map[string]time.Duration{
+	groupVotes:         30 * 24 * time.Hour,
+	groupRelease:       7 * 24 * time.Hour,
+	groupDetails:       365 * 24 * time.Hour,
+	groupCollections:   90 * 24 * time.Hour,
+	groupFilmographies: 180 * 24 * time.Hour,
+}
+
+// noTTL is the TTL of a group without -refresh: what is cached is kept whatever its age.
+const noTTL = time.Duration(math.MaxInt64)
+
+// ttlFlag is the -ttl flag: comma-separated group=duration pairs, the duration in Go syntax
+// (72h) or in days (30d).
+type ttlFlag map[string]time.Duration
+
+func (t ttlFlag) String() string {
+	groups := make([]string, 0, len(t))
+	for group, ttl := range t {
+		groups = append(groups, fmt.Sprintf("%s=%s", group, ttl))
+	}
+	sort.Strings(groups)
+	return strings.Join(groups, ",")
+}
+
+func (t ttlFlag) Set(value string) error {
+	for _, pair := range strings.Split(value, ",") {
+		group, duration, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return fmt.Errorf("expected group=duration, got %q", pair)
+		}
+		if _, known := defaultTTLs[group]; !known {
+			return fmt.Errorf("unknown field group %q (votes, release, details, collections or filmographies)", group)
+		}
+		ttl, err := parseTTL(duration)
+		if err != nil {
+			return err
+		}
+		t[group] = ttl
+	}
+	return nil
+}
+
+// parseTTL parses a Go duration, or a number of days such as 30d.
+func parseTTL(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid TTL %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("invalid TTL %q", value)
+	}
+	return ttl, nil
+}
+
+// releasePending reports whether TMDB is likely to fill in the release of the movie later:
+// not released yet (e.g. in post production), or released without runtime or release date.
+func (m MovieDetails) releasePending() bool {
+	return m.Status != "Released" || m.Runtime == 0 || m.ReleaseDate == ""
+}
+
+// age returns the time elapsed since fetchedAt, or the longest duration if the group was
+// never fetched.
+func age(fetchedAt string, now time.Time) time.Duration {
+	fetched, err := time.Parse(time.RFC3339, fetchedAt)
+	if err != nil {
+		return time.Duration(math.MaxInt64)
+	}
+	return now.Sub(fetched)
+}
+
+// staleGroups returns the field groups of a movie older than their TTL. A group fetched
+// before its fetch time was recorded is stale.
+func staleGroups(m MovieDetails, ttls map[string]time.Duration, now time.Time) []string {
+	var groups []string
+	if age(m.VotesFetchedAt, now) > ttls[groupVotes] {
+		groups = append(groups, groupVotes)
+	}
+	if m.releasePending() && age(m.ReleaseFetchedAt, now) > ttls[groupRelease] {
+		groups = append(groups, groupRelease)
+	}
+	if age(m.DetailsFetchedAt, now) > ttls[groupDetails] {
+		groups = append(groups, groupDetails)
+	}
+	return groups
+}
+
+// refreshGroups returns the cached movie with the stale groups taken from its new details,
+// so that the other groups keep their values and fetch time. An incomplete movie, or one
+// with stale details, takes every group.
+func refreshGroups(cached, fetched MovieDetails, stale []string) MovieDetails {
+	if !cached.complete() || slices.Contains(stale, groupDetails) {
+		return fetched
+	}
+	refreshed := cached
+	refreshed.FetchedAt = fetched.FetchedAt
+	if slices.Contains(stale, groupVotes) {
+		refreshed.Popularity = fetched.Popularity
+		refreshed.VoteAverage = fetched.VoteAverage
+		refreshed.VoteCount = fetched.VoteCount
+		refreshed.VotesFetchedAt = fetched.VotesFetchedAt
+	}
+	if slices.Contains(stale, groupRelease) {
+		refreshed.Status = fetched.Status
+		refreshed.ReleaseDate = fetched.ReleaseDate
+		refreshed.Runtime = fetched.Runtime
+		refreshed.ReleaseFetchedAt = fetched.ReleaseFetchedAt
+	}
+	return refreshed
+}
+
+// FieldChange is a value that changed when a movie was fetched again.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RefreshItem is a movie fetched again by -refresh, written to refresh.json.
+type RefreshItem struct {
+	LetterboxdURI string        `json:"letterboxd_uri"`
+	Title         string        `json:"title"`
+	TMDBID        int           `json:"tmdb_id"`
+	PreviousFetch string        `json:"previous_fetch,omitempty"`
+	Stale         []string      `json:"stale"`
+	Changes       []FieldChange `json:"changes"`
+}
+
+// comparedFields are the values compared by diffMovies; lists are compared by size.
+var comparedFields = []struct {
+	name  string
+	value func(MovieDetails) interface{}
+}{
+	{"title", func(m MovieDetails) interface{} { return m.Title }},
+	{"original_title", func(m MovieDetails) interface{} { return m.OriginalTitle }},
+	{"status", func(m MovieDetails) interface{} { return m.Status }},
+	{"release_date", func(m MovieDetails) interface{} { return m.ReleaseDate }},
+	{"runtime", func(m MovieDetails) interface{} { return m.Runtime }},
+	{"popularity", func(m MovieDetails) interface{} { return m.Popularity }},
+	{"vote_average", func(m MovieDetails) interface{} { return m.VoteAverage }},
+	{"vote_count", func(m MovieDetails) interface{} { return m.VoteCount }},
+	{"budget", func(m MovieDetails) interface{} { return m.Budget }},
+	{"revenue", func(m MovieDetails) interface{} { return m.Revenue }},
+	{"imdb_id", func(m MovieDetails) interface{} { return m.IMDbID }},
+	{"poster_path", func(m MovieDetails) interface{} { return m.PosterPath }},
+	{"tagline", func(m MovieDetails) interface{} { return m.Tagline }},
+	{"genres", func(m MovieDetails) interface{} { return len(m.Genres) }},
+	{"production_companies", func(m MovieDetails) interface{} { return len(m.ProductionCompanies) }},
+	{"cast", func(m MovieDetails) interface{} {
+		if m.Credits == nil {
+			return 0
+		}
+		return len(m.Credits.Cast)
+	}},
+	{"crew", func(m MovieDetails) interface{} {
+		if m.Credits == nil {
+			return 0
+		}
+		return len(m.Credits.Crew)
+	}},
+	{"keywords", func(m MovieDetails) interface{} {
+		if m.Keywords == nil {
+			return 0
+		}
+		return len(m.Keywords.Keywords)
+	}},
+}
+
+// diffMovies lists the values that differ between the cached movie and its new details.
+func diffMovies(old, new MovieDetails) []FieldChange {
+	changes := []FieldChange{}
+	for _, f := range comparedFields {
+		if o, n := f.value(old), f.value(new); o != n {
+			changes = append(changes, FieldChange{Field: f.name, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// summarizeChanges counts the movies in which each field changed, most changed first.
+func summarizeChanges(items []RefreshItem) string {
+	counts := make(map[string]int)
+	for _, item := range items {
+		for _, c := range item.Changes {
+			counts[c.Field]++
+		}
+	}
+	fields := make([]string, 0, len(counts))
+	for field := range counts {
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, fmt.Sprintf("%s %d", field, counts[field]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+	"github.com/monsieurr/letterboxd_stats_viewer/tmdbapi"
+)
+
+// countingAPI answers the collection and filmography requests with empty ones, and counts
+// them. The other requests are not expected.
+type countingAPI struct {
+	tmdbapi.API
+	collections, filmographies atomic.Int32
+}
+
+func (a *countingAPI) Collection(ctx context.Context, id int) (tmdbapi.Collection, error) {
+	a.collections.Add(1)
+	return tmdbapi.Collection{ID: id, Name: "Alien Collection"}, nil
+}
+
+func (a *countingAPI) PersonMovieCredits(ctx context.Context, id int) (tmdbapi.PersonMovieCredits, error) {
+	a.filmographies.Add(1)
+	return tmdbapi.PersonMovieCredits{ID: id}, nil
+}
+
+func TestRefreshCollectionsAndFilmographies(t *testing.T) {
+	now := time.Now()
+	movies := []MovieDetails{{Movie: store.Movie{LetterboxdURI: "https://boxd.it/2b0k", Source: "watched",
+		BelongsToCollection: &store.CollectionRef{ID: 8091},
+		Credits:             &store.Credits{Crew: []store.CrewCredit{{Person: store.Person{ID: 578, Name: "Ridley Scott"}, Job: "Director"}}}},
+		ID: 348}}
+	tests := []struct {
+		name      string
+		fetchedAt string // Fetch time of the collection and filmography in movies.db, none if empty
+		ttl       time.Duration
+		wantFetch bool
+	}{
+		{"missing", "", noTTL, true},
+		{"old without refresh", now.AddDate(-2, 0, 0).Format(time.RFC3339), noTTL, false},
+		{"stale", now.AddDate(0, 0, -100).Format(time.RFC3339), 90 * 24 * time.Hour, true},
+		{"fresh", now.AddDate(0, 0, -10).Format(time.RFC3339), 90 * 24 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			if tt.fetchedAt != "" {
+				if _, err := store.SaveCollection(db, store.Collection{ID: 8091, Name: "Alien Collection", FetchedAt: tt.fetchedAt}); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := store.SaveFilmography(db, store.Filmography{PersonID: 578, Name: "Ridley Scott", FetchedAt: tt.fetchedAt}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			api := &countingAPI{}
+			if err := updateCollections(context.Background(), api, db, movies, tt.ttl); err != nil {
+				t.Fatal(err)
+			}
+			if err := updateFilmographies(context.Background(), api, db, movies, 3, tt.ttl); err != nil {
+				t.Fatal(err)
+			}
+			want := int32(0)
+			if tt.wantFetch {
+				want = 1
+			}
+			if n := api.collections.Load(); n != want {
+				t.Errorf("collection fetched %d times, want %d", n, want)
+			}
+			if n := api.filmographies.Load(); n != want {
+				t.Errorf("filmography fetched %d times, want %d", n, want)
+			}
+
+			var fetchedAt string
+			if err := db.Get(&fetchedAt, `SELECT fetched_at FROM collections WHERE id = 8091`); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantFetch == (fetchedAt == tt.fetchedAt) {
+				t.Errorf("collection fetched_at = %q, was %q", fetchedAt, tt.fetchedAt)
+			}
+		})
+	}
+}
+
+func TestTTLFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		group   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"votes=14d", groupVotes, 14 * 24 * time.Hour, false},
+		{"release=72h", groupRelease, 72 * time.Hour, false},
+		{"collections=30d", groupCollections, 30 * 24 * time.Hour, false},
+		{"votes=1d, filmographies=365d", groupFilmographies, 365 * 24 * time.Hour, false},
+		{"posters=30d", "", 0, true},
+		{"votes=-1d", "", 0, true},
+		{"votes", "", 0, true},
+	}
+	for _, tt := range tests {
+		ttls := ttlFlag{}
+		err := ttls.Set(tt.value)
+		if (err != nil) != tt.wantErr || ttls[tt.group] != tt.want {
+			t.Errorf("Set(%q) = %v, %s = %v; want %v, error %v", tt.value, err, tt.group, ttls[tt.group], tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const journalFile = "journal.json"
+
+// Status of a journal entry
+const (
+	statusPending = "pending" // Not processed yet, or interrupted
+	statusDone    = "done"
+	statusFailed  = "failed"
+	statusReview  = "review" // Sent to review.json: not saved until an override settles it
+)
+
+// JournalEntry is the state of a Letterboxd entry in the current run.
+type JournalEntry struct {
+	LetterboxdURI string `json:"letterboxd_uri"`
+	Name          string `json:"name"`
+	Year          int    `json:"year"`
+	Source        string `json:"source"`
+	Status        string `json:"status"`
+	TMDBID        int    `json:"tmdb_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+	UpdatedAt     string `json:"updated_at,omitempty"`
+}
+
+// Journal lists the entries of a run with their state, so that -resume can skip the
+// completed ones after a crash or an interruption. It is not safe for concurrent use.
+type Journal struct {
+	StartedAt string         `json:"started_at"`
+	UpdatedAt string         `json:"updated_at"`
+	Entries   []JournalEntry `json:"entries"`
+	index     map[string]int
+}
+
+// newJournal starts the journal of a run, every entry pending. An entry in both watched
+// and watchlist is listed once, as watched.
+func newJournal(entries []MovieEntry) *Journal {
+	j := &Journal{StartedAt: time.Now().Format(time.RFC3339), index: make(map[string]int)}
+	for _, entry := range entries {
+		if _, ok := j.index[entry.LetterboxdURI]; ok || entry.LetterboxdURI == "" {
+			continue
+		}
+		j.index[entry.LetterboxdURI] = len(j.Entries)
+		j.Entries = append(j.Entries, JournalEntry{LetterboxdURI: entry.LetterboxdURI, Name: entry.Name,
+			Year: entry.Year, Source: entry.Source, Status: statusPending})
+	}
+	return j
+}
+
+// loadJournal reads the journal of the previous run.
+func loadJournal() (*Journal, error) {
+	file, err := os.Open(journalFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", journalFile, err)
+	}
+	defer file.Close()
+
+	j := &Journal{index: make(map[string]int)}
+	if err := json.NewDecoder(file).Decode(j); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", journalFile, err)
+	}
+	for i, e := range j.Entries {
+		j.index[e.LetterboxdURI] = i
+	}
+	return j, nil
+}
+
+// movieEntries returns the entries of the journal, to resume the run without the export.
+func (j *Journal) movieEntries() []MovieEntry {
+	entries := make([]MovieEntry, 0, len(j.Entries))
+	for _, e := range j.Entries {
+		entries = append(entries, MovieEntry{Name: e.Name, Year: e.Year, LetterboxdURI: e.LetterboxdURI, Source: e.Source})
+	}
+	return entries
+}
+
+// status returns the status of an entry, pending if it is not in the journal.
+func (j *Journal) status(uri string) string {
+	if i, ok := j.index[uri]; ok {
+		return j.Entries[i].Status
+	}
+	return statusPending
+}
+
+// done marks an entry as fetched, or found in the cache.
+func (j *Journal) done(uri string, tmdbID int) {
+	j.set(uri, statusDone, tmdbID, "")
+}
+
+// fail marks an entry as failed. An entry interrupted by Ctrl-C stays pending.
+func (j *Journal) fail(uri string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	j.set(uri, statusFailed, 0, err.Error())
+}
+
+// review marks an entry whose match was sent to the review queue.
+func (j *Journal) review(uri, reason string) {
+	j.set(uri, statusReview, 0, reason)
+}
+
+func (j *Journal) set(uri, status string, tmdbID int, message string) {
+	i, ok := j.index[uri]
+	if !ok {
+		return
+	}
+	e := &j.Entries[i]
+	e.Status = status
+	e.Error = message
+	if tmdbID != 0 {
+		e.TMDBID = tmdbID
+	}
+	e.UpdatedAt = time.Now().Format(time.RFC3339)
+}
+
+// counts returns the number of pending, done, failed and in review entries.
+func (j *Journal) counts() (pending, done, failed, review int) {
+	for _, e := range j.Entries {
+		switch e.Status {
+		case statusDone:
+			done++
+		case statusFailed:
+			failed++
+		case statusReview:
+			review++
+		default:
+			pending++
+		}
+	}
+	return pending, done, failed, review
+}
+
+// save writes the journal to journal.json.
+func (j *Journal) save() error {
+	j.UpdatedAt = time.Now().Format(time.RFC3339)
+	return writeJSONAtomic(journalFile, j)
+}
+
+// writeJSONAtomic writes v as indented JSON to a temporary file renamed over path, so that
+// a crash while writing leaves the previous version intact.
+func writeJSONAtomic(path string, v interface{}) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
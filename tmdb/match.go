@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/tmdbapi"
+)
+
+const (
+	overridesFile = "overrides.json"
+	reviewFile    = "review.json"
+
+	// Weights of the match score, which ranges from 0 to 1
+	titleWeight      = 0.55
+	yearWeight       = 0.25
+	popularityWeight = 0.10
+	voteCountWeight  = 0.10
+
+	// A match is sent to review below this score, below this title similarity, more than a
+	// year apart, or when the runner-up is closer than minScoreMargin
+	minMatchScore   = 0.75
+	minTitleScore   = 0.8
+	minYearScore    = 0.8
+	minScoreMargin  = 0.05
+	maxReviewChoice = 5 // Candidates listed in review.json
+
+	unknownYearScore = 0.5 // Score of a movie without release date, or of an entry without year
+)
+
+// Candidate is a search result with its match score.
+type Candidate struct {
+	ID            int     `json:"id"`
+	Title         string  `json:"title"`
+	OriginalTitle string  `json:"original_title"`
+	ReleaseDate   string  `json:"release_date"`
+	Popularity    float64 `json:"popularity"`
+	VoteCount     int     `json:"vote_count"`
+	Score         float64 `json:"score"`
+	TitleScore    float64 `json:"title_score"`
+	YearScore     float64 `json:"year_score"`
+}
+
+// Match is the outcome of a search: the best candidate, and why it needs review if it is
+// not a confident match.
+type Match struct {
+	ID         int
+	Score      float64
+	Review     string // Empty for a confident match
+	Candidates []Candidate
+}
+
+// ReviewItem is a low-confidence or missing match written to review.json. Adding
+// "letterboxd_uri": tmdb_id to overrides.json resolves it.
+type ReviewItem struct {
+	LetterboxdURI string      `json:"letterboxd_uri"`
+	Name          string      `json:"name"`
+	Year          int         `json:"year"`
+	Reason        string      `json:"reason"`
+	ChosenID      int         `json:"chosen_id,omitempty"`
+	Candidates    []Candidate `json:"candidates"`
+}
+
+// loadOverrides reads overrides.json, which maps Letterboxd URIs to the TMDB id to use
+// instead of searching.
+func loadOverrides() (map[string]int, error) {
+	overrides := make(map[string]int)
+	file, err := os.Open(overridesFile)
+	if os.IsNotExist(err) {
+		return overrides, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", overridesFile, err)
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(&overrides); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse %s: %w", overridesFile, err)
+	}
+	return overrides, nil
+}
+
+// loadReview reads the review queue of previous runs, keyed by Letterboxd URI.
+func loadReview() (map[string]ReviewItem, error) {
+	items := make(map[string]ReviewItem)
+	file, err := os.Open(reviewFile)
+	if os.IsNotExist(err) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", reviewFile, err)
+	}
+	defer file.Close()
+
+	var list []ReviewItem
+	if err := json.NewDecoder(file).Decode(&list); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse %s: %w", reviewFile, err)
+	}
+	for _, item := range list {
+		items[item.LetterboxdURI] = item
+	}
+	return items, nil
+}
+
+// saveReview writes the review queue, sorted by Letterboxd URI.
+func saveReview(items map[string]ReviewItem) error {
+	list := make([]ReviewItem, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LetterboxdURI < list[j].LetterboxdURI })
+	return writeJSONAtomic(reviewFile, list)
+}
+
+// accents folds the accented letters most common in film titles.
+var accents = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "æ", "ae",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "œ", "oe",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+	"&", " and ",
+)
+
+// normalizeTitle lowercases a title, folds accents and keeps only letters and digits
+// separated by single spaces.
+func normalizeTitle(title string) string {
+	title = accents.Replace(strings.ToLower(title))
+	var b strings.Builder
+	space := false
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// titleSimilarity is 1 minus the edit distance between the normalized titles, relative
+// to the longest.
+func titleSimilarity(a, b string) float64 {
+	ra, rb := []rune(normalizeTitle(a)), []rune(normalizeTitle(b))
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+// yearScore rates the distance between the Letterboxd year and the TMDB release date:
+// release dates often differ by a year between countries, rarely by more.
+func yearScore(year int, releaseDate string) float64 {
+	if year == 0 || len(releaseDate) < 4 {
+		return unknownYearScore
+	}
+	released, err := strconv.Atoi(releaseDate[:4])
+	if err != nil {
+		return unknownYearScore
+	}
+	switch d := released - year; {
+	case d == 0:
+		return 1
+	case d == 1 || d == -1:
+		return 0.8
+	case d == 2 || d == -2:
+		return 0.3
+	default:
+		return 0
+	}
+}
+
+// scoreCandidates scores search results against a Letterboxd entry, best first.
+// Popularity and vote count are relative to the most popular result, on a log scale.
+func scoreCandidates(name string, year int, results []tmdbapi.SearchResult) []Candidate {
+	var maxPopularity float64
+	var maxVotes int
+	for _, r := range results {
+		maxPopularity = max(maxPopularity, r.Popularity)
+		maxVotes = max(maxVotes, r.VoteCount)
+	}
+
+	candidates := make([]Candidate, 0, len(results))
+	for _, r := range results {
+		c := Candidate{
+			ID:            r.ID,
+			Title:         r.Title,
+			OriginalTitle: r.OriginalTitle,
+			ReleaseDate:   r.ReleaseDate,
+			Popularity:    r.Popularity,
+			VoteCount:     r.VoteCount,
+			TitleScore:    max(titleSimilarity(name, r.Title), titleSimilarity(name, r.OriginalTitle)),
+			YearScore:     yearScore(year, r.ReleaseDate),
+		}
+		c.Score = titleWeight*c.TitleScore + yearWeight*c.YearScore
+		if maxPopularity > 0 {
+			c.Score += popularityWeight * math.Log1p(r.Popularity) / math.Log1p(maxPopularity)
+		}
+		if maxVotes > 0 {
+			c.Score += voteCountWeight * math.Log1p(float64(r.VoteCount)) / math.Log1p(float64(maxVotes))
+		}
+		c.Score = math.Round(c.Score*1000) / 1000
+		candidates = append(candidates, c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	return candidates
+}
+
+// pickMatch takes the best candidate and tells why it needs review, if it does.
+func pickMatch(candidates []Candidate) Match {
+	if len(candidates) == 0 {
+		return Match{Review: "no results"}
+	}
+	best := candidates[0]
+	m := Match{ID: best.ID, Score: best.Score, Candidates: candidates[:min(maxReviewChoice, len(candidates))]}
+	switch {
+	case best.Score < minMatchScore:
+		m.Review = fmt.Sprintf("low score %.2f", best.Score)
+	case best.TitleScore < minTitleScore:
+		m.Review = fmt.Sprintf("title mismatch (%.2f)", best.TitleScore)
+	case best.YearScore < minYearScore && best.YearScore != unknownYearScore:
+		m.Review = "release year differs by more than one year"
+	case len(candidates) > 1 && best.Score-candidates[1].Score < minScoreMargin:
+		m.Review = fmt.Sprintf("ambiguous with %s (%s)", candidates[1].Title, candidates[1].ReleaseDate)
+	}
+	return m
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+	"github.com/monsieurr/letterboxd_stats_viewer/tmdbapi"
+)
+
+// storeMovie maps the TMDB details of a movie to the movie saved in movies.db, without its
+// Letterboxd fields. Credits, keywords and external ids stay nil when they were not fetched.
+func storeMovie(d tmdbapi.MovieDetails) store.Movie {
+	m := store.Movie{TMDBID: d.ID, Title: d.Title, OriginalTitle: d.OriginalTitle, Overview: d.Overview,
+		ReleaseDate: d.ReleaseDate, PosterPath: d.PosterPath, Popularity: d.Popularity,
+		VoteAverage: d.VoteAverage, VoteCount: d.VoteCount, Adult: d.Adult,
+		OriginalLanguage: d.OriginalLanguage, Runtime: d.Runtime, Tagline: d.Tagline, Status: d.Status,
+		Budget: d.Budget, Revenue: d.Revenue, IMDbID: d.IMDbID}
+
+	for _, g := range d.Genres {
+		m.Genres = append(m.Genres, store.Genre{ID: g.ID, Name: g.Name})
+	}
+	for _, c := range d.ProductionCountries {
+		m.ProductionCountries = append(m.ProductionCountries, store.ProductionCountry{ISO3166_1: c.ISO3166_1, Name: c.Name})
+	}
+	for _, c := range d.ProductionCompanies {
+		m.ProductionCompanies = append(m.ProductionCompanies, store.ProductionCompany{ID: c.ID, Name: c.Name,
+			LogoPath: c.LogoPath, OriginCountry: c.OriginCountry})
+	}
+	for _, l := range d.SpokenLanguages {
+		m.SpokenLanguages = append(m.SpokenLanguages, store.SpokenLanguage{ISO639_1: l.ISO639_1,
+			EnglishName: l.EnglishName, Name: l.Name})
+	}
+	if c := d.BelongsToCollection; c != nil {
+		m.BelongsToCollection = &store.CollectionRef{ID: c.ID, Name: c.Name, PosterPath: c.PosterPath,
+			BackdropPath: c.BackdropPath}
+	}
+
+	if d.Credits != nil {
+		m.Credits = &store.Credits{}
+		for _, c := range d.Credits.Cast {
+			m.Credits.Cast = append(m.Credits.Cast, store.CastCredit{
+				Person:   store.Person{ID: c.ID, Name: c.Name, ProfilePath: c.ProfilePath, KnownForDepartment: c.KnownForDepartment},
+				CreditID: c.CreditID, Character: c.Character, Order: c.Order})
+		}
+		for _, c := range d.Credits.Crew {
+			m.Credits.Crew = append(m.Credits.Crew, store.CrewCredit{
+				Person:   store.Person{ID: c.ID, Name: c.Name, ProfilePath: c.ProfilePath, KnownForDepartment: c.KnownForDepartment},
+				CreditID: c.CreditID, Job: c.Job, Department: c.Department})
+		}
+	}
+	if d.Keywords != nil {
+		m.Keywords = &store.Keywords{}
+		for _, k := range d.Keywords.Keywords {
+			m.Keywords.Keywords = append(m.Keywords.Keywords, store.Keyword{ID: k.ID, Name: k.Name})
+		}
+	}
+	if d.ExternalIDs != nil {
+		m.ExternalIDs = &store.ExternalIDs{IMDbID: d.ExternalIDs.IMDbID}
+	}
+	return m
+}
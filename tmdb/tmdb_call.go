@@ -1,93 +1,44 @@
 package main
 
 import (
+	"archive/zip"
+	"context"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
-	"net/url"
+	"maps"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/joho/godotenv"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+	"github.com/monsieurr/letterboxd_stats_viewer/tmdbapi"
 )
 
 const (
-	baseURL           = "https://api.themoviedb.org/3/"
 	apiKeyEnv         = "TMDB_API_KEY"
-	outputFile        = "output.json"
-	rateLimitPerSec   = 20 // Conservative rate limit (well below the 50/sec limit)
-	maxConcurrentReqs = 5  // Maximum concurrent requests
-)
-
-var (
-	apiKey      string
-	httpClient  = &http.Client{Timeout: 10 * time.Second}
-	rateLimiter = time.NewTicker(time.Second / time.Duration(rateLimitPerSec))
+	maxConcurrentReqs = 5 // Maximum concurrent requests
 )
 
-type Movie struct {
-	ID            int     `json:"id"`
-	Title         string  `json:"title"`
-	OriginalTitle string  `json:"original_title"`
-	Overview      string  `json:"overview"`
-	ReleaseDate   string  `json:"release_date"`
-	PosterPath    string  `json:"poster_path"`
-	Popularity    float64 `json:"popularity"`
-	VoteAverage   float64 `json:"vote_average"`
-	VoteCount     int     `json:"vote_count"`
-	Adult         bool    `json:"adult"`
-	GenreIDs      []int   `json:"genre_ids"`
-}
-
-type Genre struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
-}
-
-type ProductionCountry struct {
-	Iso3166_1 string `json:"iso_3166_1"`
-	Name      string `json:"name"`
-}
-
+// MovieDetails is a movie fetched from TMDB, with the Letterboxd entry it was matched to.
+// ID is the TMDB id, as in the TMDB responses.
 type MovieDetails struct {
-	ID                  int                 `json:"id"`
-	Title               string              `json:"title"`
-	OriginalTitle       string              `json:"original_title"`
-	Overview            string              `json:"overview"`
-	ReleaseDate         string              `json:"release_date"`
-	PosterPath          string              `json:"poster_path"`
-	Popularity          float64             `json:"popularity"`
-	VoteAverage         float64             `json:"vote_average"`
-	VoteCount           int                 `json:"vote_count"`
-	Adult               bool                `json:"adult"`
-	Genres              []Genre             `json:"genres"`
-	OriginalLanguage    string              `json:"original_language"`
-	ProductionCountries []ProductionCountry `json:"production_countries"`
-	Runtime             int                 `json:"runtime"`
-	Tagline             string              `json:"tagline,omitempty"`
-	Status              string              `json:"status,omitempty"`
-	Source              string              `json:"source,omitempty"` // 'watched' or 'watchlist'
-	Year                int                 `json:"year,omitempty"`
-	LetterboxdURI       string              `json:"letterboxd_uri,omitempty"`
-}
-
-type MovieSearchResponse struct {
-	Page         int     `json:"page"`
-	Results      []Movie `json:"results"`
-	TotalPages   int     `json:"total_pages"`
-	TotalResults int     `json:"total_results"`
-}
-
-type ErrorResponse struct {
-	StatusCode    int    `json:"status_code"`
-	StatusMessage string `json:"status_message"`
-	Success       *bool  `json:"success,omitempty"`
+	store.Movie
+	ID int `json:"id"`
 }
 
 type MovieEntry struct {
@@ -98,71 +49,63 @@ type MovieEntry struct {
 	Source        string // 'watched' or 'watchlist'
 }
 
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("⚠️ Warning: No .env file found")
-	}
-
-	apiKey = os.Getenv(apiKeyEnv)
-	if apiKey == "" {
-		fmt.Printf("=============================================================\n")
-		fmt.Printf("❌ ERROR: API Key not found. Set %s environment variable\n", apiKeyEnv)
-		fmt.Printf("=============================================================\n")
-		os.Exit(1)
+// acquire takes a slot of the semaphore, unless ctx is cancelled first.
+func acquire(ctx context.Context, semaphore chan struct{}) bool {
+	select {
+	case semaphore <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
-func makeTmdbRequest(endpoint string, queryParams map[string]string, target interface{}) error {
-	// Wait for rate limiter
-	<-rateLimiter.C
-
-	base, _ := url.Parse(baseURL)
-	endpointURL, _ := base.Parse(endpoint)
-
-	params := url.Values{}
-	params.Add("api_key", apiKey)
-	for key, value := range queryParams {
-		params.Add(key, value)
+// openExportCSV opens a CSV from the Letterboxd export: the most recent ../letterboxd-*.zip
+// archive, read in place, or the unzipped ../stats folder of older versions when there is no
+// archive. As in the server, a stats folder left next to an archive is ignored.
+func openExportCSV(name string) (io.ReadCloser, error) {
+	archives, _ := filepath.Glob(filepath.Join("..", "letterboxd-*.zip"))
+	if len(archives) == 0 {
+		file, err := os.Open(filepath.Join("..", "stats", name))
+		if err != nil {
+			return nil, fmt.Errorf("no ../letterboxd-*.zip archive or ../stats folder found: %w", err)
+		}
+		log.Printf("Reading %s from ../stats (no letterboxd-*.zip archive)", name)
+		return file, nil
 	}
-	endpointURL.RawQuery = params.Encode()
-
-	req, _ := http.NewRequest("GET", endpointURL.String(), nil)
-	req.Header.Add("Accept", "application/json")
-
-	resp, err := httpClient.Do(req)
+	sort.Strings(archives)
+	log.Printf("Reading %s from %s", name, archives[len(archives)-1])
+	archive, err := zip.OpenReader(archives[len(archives)-1])
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return nil, fmt.Errorf("failed to open archive: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusTooManyRequests {
-		// Handle rate limiting - wait and retry
-		retryAfter := 1 // Default to 1 second if header not present
-		if s := resp.Header.Get("Retry-After"); s != "" {
-			if i, err := strconv.Atoi(s); err == nil {
-				retryAfter = i
-			}
+	// The archive may wrap the export in a top-level folder; take the shallowest match
+	var match *zip.File
+	for _, f := range archive.File {
+		if path.Base(f.Name) != name || strings.HasPrefix(f.Name, "deleted/") || strings.HasPrefix(f.Name, "orphaned/") {
+			continue
 		}
-		fmt.Printf("Rate limit exceeded. Waiting %d seconds before retry...\n", retryAfter)
-		time.Sleep(time.Duration(retryAfter) * time.Second)
-		return makeTmdbRequest(endpoint, queryParams, target) // Retry the request
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var apiError ErrorResponse
-		json.NewDecoder(resp.Body).Decode(&apiError)
-		if apiError.StatusMessage != "" {
-			return fmt.Errorf("API error: %s (Code: %d)", apiError.StatusMessage, apiError.StatusCode)
+		if match == nil || strings.Count(f.Name, "/") < strings.Count(match.Name, "/") {
+			match = f
 		}
-		return fmt.Errorf("unexpected status: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 	}
-
-	return json.NewDecoder(resp.Body).Decode(target)
+	if match == nil {
+		archive.Close()
+		return nil, fmt.Errorf("%s not found in %s", name, archives[len(archives)-1])
+	}
+	rc, err := match.Open()
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{rc, archive}, nil
 }
 
 func readCSVFile(filePath string, source string) ([]MovieEntry, error) {
-	file, err := os.Open(filePath)
+	file, err := openExportCSV(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
@@ -204,77 +147,131 @@ func readCSVFile(filePath string, source string) ([]MovieEntry, error) {
 	return entries, nil
 }
 
-func loadExistingMovies() (map[string]MovieDetails, error) {
-	existingMovies := make(map[string]MovieDetails)
-
-	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
-		// File doesn't exist, return empty map
-		return existingMovies, nil
+// loadExistingMovies reads the movies already fetched into movies.db. A movie saved without
+// its TMDB id, because another film already has it, keeps that id so that it is not
+// searched and fetched again.
+func loadExistingMovies(db *sqlx.DB) ([]MovieDetails, error) {
+	stored, err := store.LoadMovies(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load movies from the database: %w", err)
+	}
+	movies := make([]MovieDetails, 0, len(stored))
+	for _, m := range stored {
+		id := m.TMDBID
+		if id == 0 {
+			id = m.TMDBConflictID
+		}
+		movies = append(movies, MovieDetails{Movie: m, ID: id})
 	}
+	return movies, nil
+}
 
-	file, err := os.Open(outputFile)
+// inTx runs fn in a transaction of movies.db, committed if fn succeeds.
+func inTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
 	if err != nil {
-		return nil, fmt.Errorf("failed to open existing output file: %w", err)
+		return err
 	}
-	defer file.Close()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
-	var movies []MovieDetails
-	if err := json.NewDecoder(file).Decode(&movies); err != nil {
-		// If the file is empty or invalid JSON, return empty map
-		if err == io.EOF {
-			return existingMovies, nil
+// saveMovie writes a movie and its details to movies.db in one transaction.
+func saveMovie(db *sqlx.DB, movie MovieDetails) (store.Result, error) {
+	var res store.Result
+	movie.TMDBID = movie.ID
+	err := inTx(db, func(tx *sqlx.Tx) error {
+		var err error
+		res, err = store.SaveMovie(tx, movie.Movie)
+		return err
+	})
+	return res, err
+}
+
+// movieCache indexes the movies of movies.db by Letterboxd URI, and by TMDB id so that
+// two Letterboxd entries for the same film share their details.
+type movieCache struct {
+	byURI    map[string]MovieDetails
+	byTMDBID map[int]MovieDetails
+}
+
+// newMovieCache indexes the cached movies.
+func newMovieCache(movies []MovieDetails) movieCache {
+	cache := movieCache{byURI: make(map[string]MovieDetails), byTMDBID: make(map[int]MovieDetails)}
+	for _, movie := range movies {
+		cache.byURI[movie.LetterboxdURI] = movie
+		if movie.ID != 0 && movie.TMDBConflictID == 0 && movie.complete() {
+			cache.byTMDBID[movie.ID] = movie
 		}
-		return nil, fmt.Errorf("failed to parse existing JSON: %w", err)
 	}
+	return cache
+}
 
-	// Create a map for quick lookup
+// fill appends to movies the cached details of the entries missing from it, so that movies
+// not fetched in this run are not lost.
+func (c movieCache) fill(movies []MovieDetails, entries []MovieEntry) []MovieDetails {
+	saved := make(map[string]bool, len(movies))
 	for _, movie := range movies {
-		key := fmt.Sprintf("%s_%d", movie.Title, movie.Year)
-		existingMovies[key] = movie
+		saved[movie.LetterboxdURI] = true
 	}
+	filled := slices.Clone(movies)
+	for _, entry := range entries {
+		if movie, ok := c.byURI[entry.LetterboxdURI]; ok && !saved[entry.LetterboxdURI] {
+			saved[entry.LetterboxdURI] = true
+			filled = append(filled, movie)
+		}
+	}
+	return filled
+}
 
-	return existingMovies, nil
+// complete reports whether the movie was fully fetched, with its credits, keywords and
+// external ids, even if TMDB has none; movies fetched by an older version get their details
+// fetched again.
+func (m MovieDetails) complete() bool {
+	return m.DetailsFetchedAt != ""
 }
 
-func searchMovie(title string, year int) (int, error) {
-	searchParams := map[string]string{
-		"query":         title,
-		"language":      "en-US",
-		"include_adult": "false",
-		"year":          strconv.Itoa(year),
+// searchMovie searches TMDB for a Letterboxd entry and scores the results. Remakes and
+// films released under another year are only found without the year constraint, so that
+// search is tried too when the first one finds no confident match.
+func searchMovie(ctx context.Context, api tmdbapi.API, title string, year int) (Match, error) {
+	results, err := api.SearchMovie(ctx, title, year)
+	if err != nil {
+		return Match{}, err
 	}
-
-	var searchResults MovieSearchResponse
-	if err := makeTmdbRequest("search/movie", searchParams, &searchResults); err != nil {
-		return 0, fmt.Errorf("search failed: %w", err)
+	match := pickMatch(scoreCandidates(title, year, results))
+	if match.Review == "" || year == 0 {
+		return match, nil
 	}
 
-	if len(searchResults.Results) == 0 {
-		// Try again without year constraint
-		delete(searchParams, "year")
-		if err := makeTmdbRequest("search/movie", searchParams, &searchResults); err != nil {
-			return 0, fmt.Errorf("secondary search failed: %w", err)
-		}
-
-		if len(searchResults.Results) == 0 {
-			return 0, fmt.Errorf("no results found for %s (%d)", title, year)
+	// Try again without year constraint
+	anyYear, err := api.SearchMovie(ctx, title, 0)
+	if err != nil {
+		return Match{}, fmt.Errorf("secondary %w", err)
+	}
+	for _, r := range anyYear {
+		if !slices.ContainsFunc(results, func(m tmdbapi.SearchResult) bool { return m.ID == r.ID }) {
+			results = append(results, r)
 		}
 	}
-
-	// Select best match (first result should be most relevant)
-	return searchResults.Results[0].ID, nil
+	return pickMatch(scoreCandidates(title, year, results)), nil
 }
 
-func getMovieDetails(id int, entry MovieEntry) (MovieDetails, error) {
-	var details MovieDetails
-	endpoint := fmt.Sprintf("movie/%d", id)
-	params := map[string]string{
-		"language": "en-US",
-	}
-
-	if err := makeTmdbRequest(endpoint, params, &details); err != nil {
-		return details, fmt.Errorf("failed to fetch details: %w", err)
+// getMovieDetails fetches the details of a movie, with its credits, keywords and external
+// ids, for a Letterboxd entry.
+func getMovieDetails(ctx context.Context, api tmdbapi.API, id int, entry MovieEntry) (MovieDetails, error) {
+	fetched, err := api.MovieDetails(ctx, id)
+	if err != nil {
+		return MovieDetails{}, err
 	}
+	details := MovieDetails{Movie: storeMovie(fetched), ID: fetched.ID}
+	details.FetchedAt = time.Now().Format(time.RFC3339)
+	details.VotesFetchedAt = details.FetchedAt
+	details.ReleaseFetchedAt = details.FetchedAt
+	details.DetailsFetchedAt = details.FetchedAt
 
 	// Add letterboxd metadata
 	details.Source = entry.Source
@@ -285,115 +282,403 @@ func getMovieDetails(id int, entry MovieEntry) (MovieDetails, error) {
 }
 
 func main() {
-	if apiKey == "" {
-		log.Fatal("API key not set")
+	fetchFilmographies := flag.Bool("filmographies", false, "also fetch the filmographies of directors and top-billed actors of watched movies into movies.db")
+	castDepth := flag.Int("cast-depth", 3, "with -filmographies, number of top-billed actors per movie")
+	resume := flag.Bool("resume", false, "resume the run recorded in journal.json: skip done entries, retry failed ones")
+	checkpointEvery := flag.Int("checkpoint-every", 25, "save journal.json every N fetched movies")
+	dbPath := flag.String("db", filepath.Join("..", "movies.db"), "SQLite database of the server, where movies are written")
+	output := flag.String("output", "", "also export the movies of the Letterboxd export to this JSON file")
+	refresh := flag.Bool("refresh", false, "fetch again the cached movies with a field group older than its TTL, and the collections and filmographies older than theirs; report what changed in the movies in "+refreshReportFile)
+	ttls := ttlFlag(maps.Clone(defaultTTLs))
+	flag.Var(ttls, "ttl", "with -refresh, TTL per field group (votes, release, details, collections, filmographies), e.g. votes=14d,release=72h")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: no .env file found")
+	}
+	api, err := tmdbapi.New(os.Getenv(apiKeyEnv), tmdbapi.WithLogger(log.Printf))
+	if errors.Is(err, tmdbapi.ErrNoAPIKey) {
+		log.Fatalf("API key not found: set the %s environment variable", apiKeyEnv)
+	}
+	if err != nil {
+		log.Fatalf("Error creating TMDB client: %v", err)
+	}
+	if *checkpointEvery < 1 {
+		log.Fatal("-checkpoint-every must be at least 1")
+	}
+
+	// Ctrl-C stops the requests and saves what has been fetched; a second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	// Movies are written to the server's database, with its schema brought up to date
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening %s: %v", *dbPath, err)
+	}
+	defer db.Close()
+	if err := store.Migrate(db); err != nil {
+		log.Fatalf("Error migrating %s: %v", *dbPath, err)
 	}
 
 	// Load existing movies to avoid duplicate fetches
-	existingMovies, err := loadExistingMovies()
+	existingMovies, err := loadExistingMovies(db)
 	if err != nil {
 		log.Fatalf("Error loading existing movies: %v", err)
 	}
-	log.Printf("Found %d existing movies in output.json", len(existingMovies))
 
-	// Read watched and watchlist CSVs
-	watched, err := readCSVFile("../stats/watched.csv", "watched")
-	if err != nil {
-		log.Fatalf("Error reading watched.csv: %v", err)
+	// A resumed run takes its entries from the journal, so the export is not read again
+	var (
+		allMovies []MovieEntry
+		journal   *Journal
+	)
+	if *resume {
+		journal, err = loadJournal()
+		if err != nil {
+			log.Fatalf("Error loading run journal: %v", err)
+		}
+		pending, done, failed, inReview := journal.counts()
+		log.Printf("Resuming run started %s: %d done, %d failed, %d in review, %d pending", journal.StartedAt, done, failed, inReview, pending)
+		allMovies = journal.movieEntries()
+	} else {
+		// Read watched and watchlist CSVs
+		watched, err := readCSVFile("watched.csv", "watched")
+		if err != nil {
+			log.Fatalf("Error reading watched.csv: %v", err)
+		}
+		log.Printf("Read %d entries from watched.csv", len(watched))
+
+		watchlist, err := readCSVFile("watchlist.csv", "watchlist")
+		if err != nil {
+			log.Fatalf("Error reading watchlist.csv: %v", err)
+		}
+		log.Printf("Read %d entries from watchlist.csv", len(watchlist))
+
+		// Combine both lists
+		allMovies = append(watched, watchlist...)
+		journal = newJournal(allMovies)
 	}
-	log.Printf("Read %d entries from watched.csv", len(watched))
+	log.Printf("Processing %d total movies", len(allMovies))
 
-	watchlist, err := readCSVFile("../stats/watchlist.csv", "watchlist")
+	overrides, err := loadOverrides()
+	if err != nil {
+		log.Fatalf("Error loading overrides: %v", err)
+	}
+	review, err := loadReview()
 	if err != nil {
-		log.Fatalf("Error reading watchlist.csv: %v", err)
+		log.Fatalf("Error loading review queue: %v", err)
 	}
-	log.Printf("Read %d entries from watchlist.csv", len(watchlist))
 
-	// Combine both lists
-	allMovies := append(watched, watchlist...)
-	log.Printf("Processing %d total movies", len(allMovies))
+	// An override wins over any earlier merge: its Letterboxd URI becomes its own film again
+	for uri := range overrides {
+		into, err := store.DetachFilm(db, uri)
+		if err != nil {
+			log.Fatalf("Error applying override of %s: %v", uri, err)
+		}
+		if into != "" {
+			log.Printf("%s was merged into %s: detached to apply its override", uri, into)
+		}
+	}
+
+	cache := newMovieCache(existingMovies)
+	log.Printf("Found %d existing movies in %s", len(cache.byURI), *dbPath)
+
+	if err := journal.save(); err != nil {
+		log.Fatalf("Error saving run journal: %v", err)
+	}
 
 	// Process movies with rate limiting and concurrency control
 	var (
 		newMovies       []MovieDetails
+		refreshed       = []RefreshItem{}
 		existingCount   int
+		fetchedCount    int
 		errorCount      int
+		reviewCount     int
 		processingMutex sync.Mutex
 		wg              sync.WaitGroup
 		semaphore       = make(chan struct{}, maxConcurrentReqs)
 	)
 
+	// record writes the details of an entry to movies.db and, every checkpointEvery fetched
+	// movies, saves the journal so that a crash loses little work
+	record := func(movie MovieDetails, fetched bool) {
+		processingMutex.Lock()
+		defer processingMutex.Unlock()
+		res, err := saveMovie(db, movie)
+		if err != nil {
+			log.Printf("Error saving %s (ID: %d): %v", movie.Title, movie.ID, err)
+			errorCount++
+			journal.fail(movie.LetterboxdURI, err)
+			return
+		}
+		if res.MergedInto != "" {
+			log.Printf("%s is the same TMDB movie as %s: merged", movie.LetterboxdURI, res.MergedInto)
+		}
+		if res.TMDBConflict != "" {
+			log.Printf("TMDB id %d of %s already belongs to %s: saved without it", movie.ID, movie.LetterboxdURI, res.TMDBConflict)
+		}
+		if res.IMDbConflict != "" {
+			log.Printf("IMDb id %s of %s already belongs to %s: not saved", movie.IMDbID, movie.Title, res.IMDbConflict)
+		}
+		newMovies = append(newMovies, movie)
+		journal.done(movie.LetterboxdURI, movie.ID)
+		if !fetched {
+			return
+		}
+		fetchedCount++
+		if fetchedCount%*checkpointEvery != 0 {
+			return
+		}
+		if err := journal.save(); err != nil {
+			log.Printf("Error saving checkpoint: %v", err)
+			return
+		}
+		log.Printf("Checkpoint: %d movies fetched", fetchedCount)
+	}
+	// keep marks an entry done with its details unchanged in movies.db, which is not written
+	keep := func(movie MovieDetails) {
+		processingMutex.Lock()
+		defer processingMutex.Unlock()
+		newMovies = append(newMovies, movie)
+		journal.done(movie.LetterboxdURI, movie.ID)
+	}
+	// fail counts an entry that could not be fetched; resuming the run retries it
+	fail := func(uri string, err error) {
+		processingMutex.Lock()
+		defer processingMutex.Unlock()
+		errorCount++
+		journal.fail(uri, err)
+	}
+
+	now := time.Now()
+	processed := make(map[string]bool)
 	for _, entry := range allMovies {
-		// Check if already exists
-		key := fmt.Sprintf("%s_%d", entry.Name, entry.Year)
-		if movie, exists := existingMovies[key]; exists {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// A film in both watched and watchlist is processed once, as watched
+		if entry.LetterboxdURI == "" || processed[entry.LetterboxdURI] {
+			continue
+		}
+		processed[entry.LetterboxdURI] = true
+
+		// Done before the run was interrupted: the cached details are saved at the end
+		overrideID, overridden := overrides[entry.LetterboxdURI]
+		switch journal.status(entry.LetterboxdURI) {
+		case statusDone:
+			existingCount++
+			continue
+		case statusReview:
+			// Still waiting for an override, which resuming does not search again
+			if !overridden {
+				processingMutex.Lock()
+				reviewCount++
+				processingMutex.Unlock()
+				continue
+			}
+		}
+
+		// Check if already exists, with the TMDB id set in overrides.json if there is one
+		if overridden {
+			delete(review, entry.LetterboxdURI)
+		}
+		if movie, exists := cache.byURI[entry.LetterboxdURI]; exists && (!overridden || movie.ID == overrideID) {
 			log.Printf("Movie already in database: %s (%d)", entry.Name, entry.Year)
 			existingCount++
-			newMovies = append(newMovies, movie)
+			// The film may have moved from the watchlist to watched since it was fetched
+			moved := movie.Source != entry.Source || movie.Year != entry.Year
+			movie.Source = entry.Source
+			movie.Year = entry.Year
+			var stale []string
+			if *refresh {
+				stale = staleGroups(movie, ttls, now)
+			}
+			if movie.ID == 0 || (movie.complete() && len(stale) == 0) {
+				if moved {
+					record(movie, false)
+				} else {
+					keep(movie)
+				}
+				continue
+			}
+
+			// Fetched by an older version without credits, keywords or external ids, or stale:
+			// fetch the details again, without searching
+			if !movie.complete() {
+				stale = append(stale, "missing parts")
+			}
+			log.Printf("Refreshing %s (stale: %s)", movie.Title, strings.Join(stale, ", "))
+			if !acquire(ctx, semaphore) {
+				break
+			}
+			wg.Add(1)
+			go func(movie MovieDetails, stale []string) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				entry := MovieEntry{Year: movie.Year, LetterboxdURI: movie.LetterboxdURI, Source: movie.Source}
+				fetched, err := getMovieDetails(ctx, api, movie.ID, entry)
+				if err != nil {
+					// The cached details are kept, and the refresh is tried again on resume
+					log.Printf("Error refreshing details for %s (ID: %d): %v", movie.Title, movie.ID, err)
+					fail(movie.LetterboxdURI, err)
+					return
+				}
+				fetched.MatchScore = movie.MatchScore
+				details := refreshGroups(movie, fetched, stale)
+
+				item := RefreshItem{LetterboxdURI: movie.LetterboxdURI, Title: details.Title, TMDBID: details.ID,
+					PreviousFetch: movie.FetchedAt, Stale: stale, Changes: diffMovies(movie, details)}
+				for _, c := range item.Changes {
+					log.Printf("  %s: %s %v -> %v", details.Title, c.Field, c.Old, c.New)
+				}
+				processingMutex.Lock()
+				refreshed = append(refreshed, item)
+				processingMutex.Unlock()
+				record(details, true)
+			}(movie, stale)
 			continue
 		}
 
+		if !acquire(ctx, semaphore) {
+			break
+		}
 		wg.Add(1)
-		semaphore <- struct{}{} // Acquire semaphore
 
 		go func(entry MovieEntry) {
 			defer wg.Done()
 			defer func() { <-semaphore }() // Release semaphore
 
-			log.Printf("Searching for: %s (%d)", entry.Name, entry.Year)
+			match := Match{ID: overrideID, Score: 1}
+			if !overridden {
+				log.Printf("Searching for: %s (%d)", entry.Name, entry.Year)
 
-			movieID, err := searchMovie(entry.Name, entry.Year)
-			if err != nil {
-				log.Printf("Error searching for %s (%d): %v", entry.Name, entry.Year, err)
+				var err error
+				match, err = searchMovie(ctx, api, entry.Name, entry.Year)
+				if err != nil {
+					log.Printf("Error searching for %s (%d): %v", entry.Name, entry.Year, err)
+					fail(entry.LetterboxdURI, err)
+					return
+				}
+
+				// Low-confidence matches and movies not found are listed in review.json, and
+				// not saved until an override settles them
 				processingMutex.Lock()
-				errorCount++
+				if match.Review != "" {
+					log.Printf("Match needs review for %s (%d): %s, not saved", entry.Name, entry.Year, match.Review)
+					review[entry.LetterboxdURI] = ReviewItem{LetterboxdURI: entry.LetterboxdURI, Name: entry.Name,
+						Year: entry.Year, Reason: match.Review, ChosenID: match.ID, Candidates: match.Candidates}
+					journal.review(entry.LetterboxdURI, match.Review)
+					reviewCount++
+					processingMutex.Unlock()
+					return
+				}
+				delete(review, entry.LetterboxdURI)
 				processingMutex.Unlock()
+			}
+			movieID := match.ID
+
+			// Another Letterboxd entry for the same film: reuse its details
+			if cached, ok := cache.byTMDBID[movieID]; ok {
+				cached.Source = entry.Source
+				cached.Year = entry.Year
+				cached.LetterboxdURI = entry.LetterboxdURI
+				cached.MatchScore = match.Score
+				record(cached, false)
 				return
 			}
 
-			details, err := getMovieDetails(movieID, entry)
+			details, err := getMovieDetails(ctx, api, movieID, entry)
 			if err != nil {
 				log.Printf("Error getting details for %s (ID: %d): %v", entry.Name, movieID, err)
-				processingMutex.Lock()
-				errorCount++
-				processingMutex.Unlock()
+				fail(entry.LetterboxdURI, err)
 				return
 			}
 
-			processingMutex.Lock()
-			newMovies = append(newMovies, details)
-			processingMutex.Unlock()
+			details.MatchScore = match.Score
+			record(details, true)
 
 			log.Printf("Successfully processed: %s (%d)", entry.Name, entry.Year)
 		}(entry)
 	}
 
 	wg.Wait()
-	rateLimiter.Stop()
 
-	log.Printf("Processing complete: %d existing, %d new, %d errors",
-		existingCount, len(newMovies)-existingCount, errorCount)
+	log.Printf("Processing complete: %d existing, %d fetched, %d to review, %d errors", existingCount, fetchedCount, reviewCount, errorCount)
 
-	// Ensure output directory exists
-	outputDir := filepath.Dir(outputFile)
-	if outputDir != "." && outputDir != "" {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			log.Fatalf("Failed to create output directory: %v", err)
+	if *refresh {
+		sort.Slice(refreshed, func(i, j int) bool { return refreshed[i].LetterboxdURI < refreshed[j].LetterboxdURI })
+		if err := writeJSONAtomic(refreshReportFile, refreshed); err != nil {
+			log.Fatalf("Error saving refresh report: %v", err)
+		}
+		changed := 0
+		for _, item := range refreshed {
+			if len(item.Changes) > 0 {
+				changed++
+			}
+		}
+		log.Printf("Refreshed %d movies, %d changed, see %s", len(refreshed), changed, refreshReportFile)
+		if changed > 0 {
+			log.Printf("Changed fields: %s", summarizeChanges(refreshed))
 		}
 	}
 
-	// Write to output.json
-	file, err := os.Create(outputFile)
-	if err != nil {
-		log.Fatalf("Failed to create output file: %v", err)
+	// Movies that could not be fetched again, were done before a resume, or were not reached
+	// before an interruption keep their cached details
+	newMovies = cache.fill(newMovies, allMovies)
+
+	// Films no longer in the export leave the review queue
+	inExport := make(map[string]bool)
+	for _, entry := range allMovies {
+		inExport[entry.LetterboxdURI] = true
+	}
+	for uri := range review {
+		if !inExport[uri] {
+			delete(review, uri)
+		}
+	}
+	if err := saveReview(review); err != nil {
+		log.Fatalf("Error saving review queue: %v", err)
+	}
+	log.Printf("%d matches to review in %s", len(review), reviewFile)
+
+	log.Printf("%d movies up to date in %s", len(newMovies), *dbPath)
+	if *output != "" {
+		if err := writeJSONAtomic(*output, newMovies); err != nil {
+			log.Fatalf("Failed to write JSON: %v", err)
+		}
+		log.Printf("Exported %d movies to %s", len(newMovies), *output)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(newMovies); err != nil {
-		log.Fatalf("Failed to write JSON: %v", err)
+	if err := journal.save(); err != nil {
+		log.Fatalf("Error saving run journal: %v", err)
+	}
+	pending, done, failed, inReview := journal.counts()
+	log.Printf("Run journal: %d done, %d failed, %d in review, %d pending", done, failed, inReview, pending)
+
+	if ctx.Err() != nil {
+		log.Printf("Interrupted: run again with -resume to fetch the remaining movies")
+		return
+	}
+	if failed > 0 {
+		log.Printf("Run again with -resume to retry the failed movies")
 	}
 
-	fmt.Printf("Successfully saved data for %d movies to %s\n", len(newMovies), outputFile)
+	// Without -refresh, the collections and filmographies in movies.db are kept whatever
+	// their age
+	collectionTTL, filmographyTTL := noTTL, noTTL
+	if *refresh {
+		collectionTTL, filmographyTTL = ttls[groupCollections], ttls[groupFilmographies]
+	}
+	if err := updateCollections(ctx, api, db, newMovies, collectionTTL); err != nil {
+		log.Fatalf("Error updating collections: %v", err)
+	}
+	if *fetchFilmographies {
+		if err := updateFilmographies(ctx, api, db, newMovies, *castDepth, filmographyTTL); err != nil {
+			log.Fatalf("Error updating filmographies: %v", err)
+		}
+	}
 }
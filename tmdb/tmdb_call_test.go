@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/monsieurr/letterboxd_stats_viewer/store"
+)
+
+// openTestDB returns an empty, migrated movies.db.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	output := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(output) })
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "movies.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := store.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestTMDBConflictCached(t *testing.T) {
+	db := openTestDB(t)
+	fetchedAt := time.Now().Format(time.RFC3339)
+	// Two Letterboxd films matched to the same TMDB movie
+	pair := []MovieDetails{
+		{Movie: store.Movie{LetterboxdURI: "https://boxd.it/2bf0", Title: "Solaris", Source: "watched", FetchedAt: fetchedAt}, ID: 593},
+		{Movie: store.Movie{LetterboxdURI: "https://boxd.it/2bf1", Title: "Solaris", Source: "watched", FetchedAt: fetchedAt}, ID: 593},
+	}
+
+	for run := 1; run <= 2; run++ {
+		existing, err := loadExistingMovies(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache := newMovieCache(existing)
+		for _, movie := range pair {
+			cached, ok := cache.byURI[movie.LetterboxdURI]
+			if run > 1 {
+				// What the run skips: a cached, complete movie with its TMDB id
+				if !ok || !cached.complete() || cached.ID != movie.ID {
+					t.Errorf("run %d: %s cached as %+v, want TMDB id %d", run, movie.LetterboxdURI, cached, movie.ID)
+				}
+				movie = cached
+			}
+			res, err := saveMovie(db, movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conflict := movie.LetterboxdURI == pair[1].LetterboxdURI; (res.TMDBConflict != "") != conflict {
+				t.Errorf("run %d: %s conflict = %q, want %v", run, movie.LetterboxdURI, res.TMDBConflict, conflict)
+			}
+			if run > 1 && (res.Added || res.Changed) {
+				t.Errorf("run %d: %s rewritten: %+v", run, movie.LetterboxdURI, res)
+			}
+		}
+	}
+
+	var ids []struct {
+		TMDBID     *int `db:"tmdb_id"`
+		ConflictID *int `db:"tmdb_conflict_id"`
+	}
+	if err := db.Select(&ids, `SELECT tmdb_id, tmdb_conflict_id FROM movies ORDER BY letterboxd_uri`); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0].TMDBID == nil || ids[1].TMDBID != nil || ids[1].ConflictID == nil || *ids[1].ConflictID != 593 {
+		t.Errorf("movies = %+v, want the TMDB id on the first film and as conflict id on the second", ids)
+	}
+}
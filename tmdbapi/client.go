@@ -0,0 +1,284 @@
+// Package tmdbapi is a client of the TMDB API (v3), used by tmdb_call.go. It depends on
+// nothing else in this repository, so that other programs can reuse it.
+// Client retries rate limiting, server errors and timeouts, and paces its requests with a
+// Limiter; its base URL, transport and limiter can be replaced, for instance to point it
+// at an httptest server.
+package tmdbapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultBaseURL   = "https://api.themoviedb.org/3/"
+	DefaultRateLimit = 20 // Requests per second, well below the 50/sec limit of TMDB
+	DefaultLanguage  = "en-US"
+	DefaultTimeout   = 10 * time.Second
+
+	// Retry policy of the requests
+	maxAttempts = 5
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+// ErrNoAPIKey is returned by New without an API key.
+var ErrNoAPIKey = errors.New("TMDB API key not set")
+
+// API is the part of the TMDB API used by this project. *Client implements it; a stand-in
+// can replace it where no network is wanted.
+type API interface {
+	SearchMovie(ctx context.Context, query string, year int) ([]SearchResult, error)
+	Movie(ctx context.Context, id int) (MovieDetails, error)
+	MovieDetails(ctx context.Context, id int) (MovieDetails, error)
+	Credits(ctx context.Context, movieID int) (Credits, error)
+	Person(ctx context.Context, id int) (Person, error)
+	PersonMovieCredits(ctx context.Context, id int) (PersonMovieCredits, error)
+	Collection(ctx context.Context, id int) (Collection, error)
+}
+
+var _ API = (*Client)(nil)
+
+// Client calls the TMDB API. It is safe for concurrent use.
+type Client struct {
+	apiKey   string
+	baseURL  *url.URL
+	language string
+	http     *http.Client
+	limiter  Limiter
+	logf     func(format string, args ...interface{})
+}
+
+// Option configures a Client.
+type Option func(*Client) error
+
+// WithBaseURL sends the requests to another server than DefaultBaseURL.
+func WithBaseURL(rawURL string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid base URL %q: %w", rawURL, err)
+		}
+		if !u.IsAbs() {
+			return fmt.Errorf("invalid base URL %q: not absolute", rawURL)
+		}
+		c.baseURL = u
+		return nil
+	}
+}
+
+// WithTransport makes the requests through rt instead of http.DefaultTransport. A client
+// given to WithHTTPClient is copied, not modified.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) error {
+		hc := *c.http
+		hc.Transport = rt
+		c.http = &hc
+		return nil
+	}
+}
+
+// WithHTTPClient replaces the HTTP client, and its timeout of DefaultTimeout. The client
+// is used as is: WithTransport does not modify it.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("nil HTTP client")
+		}
+		c.http = hc
+		return nil
+	}
+}
+
+// WithLimiter replaces the limiter of DefaultRateLimit requests per second.
+func WithLimiter(l Limiter) Option {
+	return func(c *Client) error {
+		c.limiter = l
+		return nil
+	}
+}
+
+// WithLanguage sets the language of titles and overviews, DefaultLanguage otherwise.
+func WithLanguage(language string) Option {
+	return func(c *Client) error {
+		c.language = language
+		return nil
+	}
+}
+
+// WithLogger reports the retried requests through logf, log.Printf for instance. They are
+// not reported otherwise.
+func WithLogger(logf func(format string, args ...interface{})) Option {
+	return func(c *Client) error {
+		c.logf = logf
+		return nil
+	}
+}
+
+// New returns a client authenticated with apiKey.
+func New(apiKey string, opts ...Option) (*Client, error) {
+	if apiKey == "" {
+		return nil, ErrNoAPIKey
+	}
+	base, _ := url.Parse(DefaultBaseURL)
+	c := &Client{
+		apiKey:   apiKey,
+		baseURL:  base,
+		language: DefaultLanguage,
+		http:     &http.Client{Timeout: DefaultTimeout},
+		limiter:  NewLimiter(DefaultRateLimit),
+		logf:     func(string, ...interface{}) {},
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// APIError is an error status returned by TMDB, with its message when it gave one.
+type APIError struct {
+	HTTPStatus    int    `json:"-"`
+	StatusCode    int    `json:"status_code"` // TMDB error code, 34 for a missing resource
+	StatusMessage string `json:"status_message"`
+}
+
+func (e *APIError) Error() string {
+	if e.StatusMessage != "" {
+		return fmt.Sprintf("API error: %s (Code: %d)", e.StatusMessage, e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status: %d %s", e.HTTPStatus, http.StatusText(e.HTTPStatus))
+}
+
+// IsNotFound reports whether err is a 404 from TMDB, for an id that does not exist.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.HTTPStatus == http.StatusNotFound
+}
+
+// retryableError marks the failures worth another attempt: rate limiting, server errors
+// and timeouts. wait is the delay asked by the server in Retry-After, if any.
+type retryableError struct {
+	err  error
+	wait time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// get calls an endpoint of the API and decodes the response into target. Rate limiting,
+// server errors and timeouts are retried up to maxAttempts times, with a jittered
+// exponential backoff or the delay given by Retry-After.
+func (c *Client) get(ctx context.Context, endpoint string, queryParams map[string]string, target interface{}) error {
+	var err error
+	var retry *retryableError
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			wait := backoff(attempt)
+			if retry.wait > 0 {
+				wait = retry.wait
+			}
+			c.logf("Retrying %s in %v (attempt %d/%d): %v", endpoint, wait.Round(time.Millisecond), attempt+1, maxAttempts, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		err = c.do(ctx, endpoint, queryParams, target)
+		if err == nil || !errors.As(err, &retry) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", maxAttempts, err)
+}
+
+// backoff is the delay before the given retry: baseBackoff doubled at each attempt, capped
+// at maxBackoff, with full jitter so that concurrent requests don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	d := min(baseBackoff<<(attempt-1), maxBackoff)
+	return d/2 + rand.N(d/2+1)
+}
+
+// parseRetryAfter reads a Retry-After header, given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// do makes a single attempt of get.
+func (c *Client) do(ctx context.Context, endpoint string, queryParams map[string]string, target interface{}) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	endpointURL, err := c.baseURL.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	params := url.Values{}
+	params.Add("api_key", c.apiKey)
+	params.Add("language", c.language)
+	for key, value := range queryParams {
+		params.Set(key, value)
+	}
+	endpointURL.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpointURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+			return &retryableError{err: fmt.Errorf("request timed out: %w", err)}
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return &retryableError{
+			err:  &APIError{HTTPStatus: resp.StatusCode},
+			wait: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	case resp.StatusCode >= 500:
+		return &retryableError{err: &APIError{HTTPStatus: resp.StatusCode}}
+	case resp.StatusCode != http.StatusOK:
+		apiErr := &APIError{HTTPStatus: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		var netErr net.Error
+		if ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+			return &retryableError{err: fmt.Errorf("response timed out: %w", err)}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package tmdbapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client of an httptest server running handler, without rate limit.
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	opts = append([]Option{WithBaseURL(srv.URL + "/3/"), WithLimiter(NewLimiter(0))}, opts...)
+	c, err := New("test-key", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewWithoutAPIKey(t *testing.T) {
+	if _, err := New(""); !errors.Is(err, ErrNoAPIKey) {
+		t.Fatalf("New(\"\") = %v, want ErrNoAPIKey", err)
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/3/movie/694" {
+			t.Errorf("path = %q, want /3/movie/694", r.URL.Path)
+		}
+		q := r.URL.Query()
+		if q.Get("api_key") != "test-key" || q.Get("language") != "fr-FR" || q.Get("append_to_response") != "credits,keywords,external_ids" {
+			t.Errorf("query = %q", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"id": 694, "title": "The Shining", "external_ids": {"imdb_id": "tt0081505"}}`))
+	}, WithLanguage("fr-FR"))
+
+	m, err := c.MovieDetails(context.Background(), 694)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID != 694 || m.Title != "The Shining" || m.IMDbID != "tt0081505" {
+		t.Errorf("MovieDetails = %+v", m)
+	}
+}
+
+func TestNilHTTPClient(t *testing.T) {
+	if _, err := New("test-key", WithHTTPClient(nil), WithTransport(http.DefaultTransport)); err == nil {
+		t.Error("New accepted a nil HTTP client")
+	}
+}
+
+func TestInvalidBaseURL(t *testing.T) {
+	if _, err := New("test-key", WithBaseURL("/3/")); err == nil {
+		t.Error("New accepted a relative base URL")
+	}
+}
+
+func TestRetryServerError(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	})
+
+	start := time.Now()
+	if _, err := c.Movie(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < baseBackoff/2 {
+		t.Errorf("retried after %v, want at least %v", elapsed, baseBackoff/2)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	})
+
+	start := time.Now()
+	if _, err := c.Movie(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s of Retry-After", elapsed)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Movie(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Movie = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, want the cancellation to stop the wait", elapsed)
+	}
+}
+
+func TestNotFoundNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status_code": 34, "status_message": "The resource you requested could not be found."}`))
+	})
+
+	_, err := c.Collection(context.Background(), 1)
+	if !IsNotFound(err) {
+		t.Fatalf("Collection = %v, want a 404", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 34 {
+		t.Errorf("error = %#v, want TMDB status code 34", apiErr)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt < 10; attempt++ {
+		d := min(baseBackoff<<(attempt-1), maxBackoff)
+		for i := 0; i < 100; i++ {
+			if b := backoff(attempt); b < d/2 || b > d {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, b, d/2, d)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("parseRetryAfter(\"3\") = %v, want 3s", d)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 50*time.Second || d > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, want about 1m", date, d)
+	}
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Errorf("parseRetryAfter(\"soon\") = %v, want 0", d)
+	}
+}
+
+// countingLimiter counts the requests it lets through.
+type countingLimiter struct{ calls atomic.Int32 }
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.calls.Add(1)
+	return ctx.Err()
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := &countingLimiter{}
+	var calls atomic.Int32
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	}, WithLimiter(limiter))
+
+	if _, err := c.Person(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if n := limiter.calls.Load(); n != 2 {
+		t.Errorf("limiter waited %d times, want once per attempt (2)", n)
+	}
+}
+
+func TestIntervalLimiter(t *testing.T) {
+	l := NewLimiter(20)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes through at once, the next ones every 50ms
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("5 requests in %v, want at least 200ms at 20 per second", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+}
+
+// headerTransport adds a header to the requests it makes.
+type headerTransport struct{}
+
+func (headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Test", "transport")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithTransport(t *testing.T) {
+	hc := &http.Client{Timeout: time.Second}
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test") != "transport" {
+			t.Error("request not made through the transport")
+		}
+		w.Write([]byte(`{"id": 1}`))
+	}, WithHTTPClient(hc), WithTransport(headerTransport{}))
+
+	if _, err := c.Movie(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if hc.Transport != nil {
+		t.Error("WithTransport modified the client given to WithHTTPClient")
+	}
+	if c.http.Timeout != time.Second {
+		t.Errorf("timeout = %v, want the 1s of the given client", c.http.Timeout)
+	}
+}
@@ -0,0 +1,33 @@
+package tmdbapi
+
+import (
+	"context"
+	"fmt"
+)
+
+// CollectionPart is a movie of a collection.
+type CollectionPart struct {
+	ID            int    `json:"id"` // TMDB movie id
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	ReleaseDate   string `json:"release_date"`
+	PosterPath    string `json:"poster_path"`
+}
+
+// Collection is a collection (saga) with its parts, in the order given by TMDB.
+type Collection struct {
+	ID         int              `json:"id"`
+	Name       string           `json:"name"`
+	Overview   string           `json:"overview"`
+	PosterPath string           `json:"poster_path"`
+	Parts      []CollectionPart `json:"parts"`
+}
+
+// Collection fetches a collection and its parts.
+func (c *Client) Collection(ctx context.Context, id int) (Collection, error) {
+	var col Collection
+	if err := c.get(ctx, fmt.Sprintf("collection/%d", id), nil, &col); err != nil {
+		return col, fmt.Errorf("failed to fetch collection: %w", err)
+	}
+	return col, nil
+}
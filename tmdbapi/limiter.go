@@ -0,0 +1,55 @@
+package tmdbapi
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter paces the requests of a Client: Wait blocks until the next request may be sent,
+// or ctx is cancelled. *rate.Limiter from golang.org/x/time/rate implements it.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// intervalLimiter lets a request through every interval, without bursts.
+type intervalLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // When the next request may be sent
+}
+
+// NewLimiter returns a limiter of perSecond requests per second; 0 or less means no limit.
+func NewLimiter(perSecond int) Limiter {
+	if perSecond <= 0 {
+		return &intervalLimiter{}
+	}
+	return &intervalLimiter{interval: time.Second / time.Duration(perSecond)}
+}
+
+func (l *intervalLimiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	wait := at.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,193 @@
+package tmdbapi
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SearchResult is a movie found by search/movie.
+type SearchResult struct {
+	ID            int     `json:"id"`
+	Title         string  `json:"title"`
+	OriginalTitle string  `json:"original_title"`
+	Overview      string  `json:"overview"`
+	ReleaseDate   string  `json:"release_date"`
+	PosterPath    string  `json:"poster_path"`
+	Popularity    float64 `json:"popularity"`
+	VoteAverage   float64 `json:"vote_average"`
+	VoteCount     int     `json:"vote_count"`
+	Adult         bool    `json:"adult"`
+	GenreIDs      []int   `json:"genre_ids"`
+}
+
+type searchResponse struct {
+	Page         int            `json:"page"`
+	Results      []SearchResult `json:"results"`
+	TotalPages   int            `json:"total_pages"`
+	TotalResults int            `json:"total_results"`
+}
+
+// Genre is a genre of a movie.
+type Genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ProductionCountry is a country where a movie was produced.
+type ProductionCountry struct {
+	ISO3166_1 string `json:"iso_3166_1"`
+	Name      string `json:"name"`
+}
+
+// ProductionCompany is a studio that produced a movie.
+type ProductionCompany struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	LogoPath      string `json:"logo_path"`
+	OriginCountry string `json:"origin_country"`
+}
+
+// SpokenLanguage is a language spoken in a movie, identified by its ISO 639-1 code.
+type SpokenLanguage struct {
+	ISO639_1    string `json:"iso_639_1"`
+	EnglishName string `json:"english_name"`
+	Name        string `json:"name"`
+}
+
+// CollectionRef is the collection (saga) a movie belongs to.
+type CollectionRef struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	PosterPath   string `json:"poster_path"`
+	BackdropPath string `json:"backdrop_path"`
+}
+
+// CastCredit is a role in the cast of a movie. Order is the billing order, 0 for the top
+// billed actor.
+type CastCredit struct {
+	ID                 int    `json:"id"` // TMDB person id
+	Name               string `json:"name"`
+	ProfilePath        string `json:"profile_path"`
+	KnownForDepartment string `json:"known_for_department"`
+	CreditID           string `json:"credit_id"`
+	Character          string `json:"character"`
+	Order              int    `json:"order"`
+}
+
+// CrewCredit is a job in the crew of a movie.
+type CrewCredit struct {
+	ID                 int    `json:"id"` // TMDB person id
+	Name               string `json:"name"`
+	ProfilePath        string `json:"profile_path"`
+	KnownForDepartment string `json:"known_for_department"`
+	CreditID           string `json:"credit_id"`
+	Job                string `json:"job"`
+	Department         string `json:"department"`
+}
+
+// Credits is the cast and crew of a movie (movie/{id}/credits).
+type Credits struct {
+	Cast []CastCredit `json:"cast"`
+	Crew []CrewCredit `json:"crew"`
+}
+
+// Keyword is a keyword of a movie.
+type Keyword struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Keywords is the keywords of a movie (append_to_response=keywords).
+type Keywords struct {
+	Keywords []Keyword `json:"keywords"`
+}
+
+// ExternalIDs is the ids of a movie in other databases (append_to_response=external_ids).
+type ExternalIDs struct {
+	IMDbID string `json:"imdb_id"`
+}
+
+// MovieDetails is a movie as returned by movie/{id}. Credits, Keywords and ExternalIDs are
+// nil unless they were appended to the response.
+type MovieDetails struct {
+	ID                  int                 `json:"id"`
+	Title               string              `json:"title"`
+	OriginalTitle       string              `json:"original_title"`
+	Overview            string              `json:"overview"`
+	ReleaseDate         string              `json:"release_date"`
+	PosterPath          string              `json:"poster_path"`
+	Popularity          float64             `json:"popularity"`
+	VoteAverage         float64             `json:"vote_average"`
+	VoteCount           int                 `json:"vote_count"`
+	Adult               bool                `json:"adult"`
+	OriginalLanguage    string              `json:"original_language"`
+	Runtime             int                 `json:"runtime"`
+	Tagline             string              `json:"tagline"`
+	Status              string              `json:"status"`
+	Budget              int64               `json:"budget"`
+	Revenue             int64               `json:"revenue"`
+	IMDbID              string              `json:"imdb_id"`
+	Genres              []Genre             `json:"genres"`
+	ProductionCountries []ProductionCountry `json:"production_countries"`
+	ProductionCompanies []ProductionCompany `json:"production_companies"`
+	SpokenLanguages     []SpokenLanguage    `json:"spoken_languages"`
+	BelongsToCollection *CollectionRef      `json:"belongs_to_collection"`
+	Credits             *Credits            `json:"credits"`
+	Keywords            *Keywords           `json:"keywords"`
+	ExternalIDs         *ExternalIDs        `json:"external_ids"`
+}
+
+// SearchMovie searches movies by title, released in year unless it is 0. Only the first
+// page of results is returned, adult movies excluded.
+func (c *Client) SearchMovie(ctx context.Context, query string, year int) ([]SearchResult, error) {
+	params := map[string]string{
+		"query":         query,
+		"include_adult": "false",
+	}
+	if year != 0 {
+		params["year"] = strconv.Itoa(year)
+	}
+	var resp searchResponse
+	if err := c.get(ctx, "search/movie", params, &resp); err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	return resp.Results, nil
+}
+
+// Movie fetches the details of a movie, without credits, keywords or external ids.
+func (c *Client) Movie(ctx context.Context, id int) (MovieDetails, error) {
+	return c.movie(ctx, id)
+}
+
+// MovieDetails fetches the details of a movie with its credits, keywords and external ids,
+// in a single request.
+func (c *Client) MovieDetails(ctx context.Context, id int) (MovieDetails, error) {
+	return c.movie(ctx, id, "credits", "keywords", "external_ids")
+}
+
+func (c *Client) movie(ctx context.Context, id int, appendToResponse ...string) (MovieDetails, error) {
+	var details MovieDetails
+	params := map[string]string{}
+	if len(appendToResponse) > 0 {
+		params["append_to_response"] = strings.Join(appendToResponse, ",")
+	}
+	if err := c.get(ctx, fmt.Sprintf("movie/%d", id), params, &details); err != nil {
+		return details, fmt.Errorf("failed to fetch details: %w", err)
+	}
+	// Movie details usually carry the IMDb id; external_ids fills it when they don't
+	if details.IMDbID == "" && details.ExternalIDs != nil {
+		details.IMDbID = details.ExternalIDs.IMDbID
+	}
+	return details, nil
+}
+
+// Credits fetches the cast and crew of a movie.
+func (c *Client) Credits(ctx context.Context, movieID int) (Credits, error) {
+	var credits Credits
+	if err := c.get(ctx, fmt.Sprintf("movie/%d/credits", movieID), nil, &credits); err != nil {
+		return credits, fmt.Errorf("failed to fetch credits: %w", err)
+	}
+	return credits, nil
+}
@@ -0,0 +1,71 @@
+package tmdbapi
+
+import (
+	"context"
+	"fmt"
+)
+
+// Person is a person as returned by person/{id}.
+type Person struct {
+	ID                 int     `json:"id"`
+	Name               string  `json:"name"`
+	Biography          string  `json:"biography"`
+	Birthday           string  `json:"birthday"`
+	Deathday           string  `json:"deathday"`
+	PlaceOfBirth       string  `json:"place_of_birth"`
+	KnownForDepartment string  `json:"known_for_department"`
+	ProfilePath        string  `json:"profile_path"`
+	Popularity         float64 `json:"popularity"`
+	IMDbID             string  `json:"imdb_id"`
+}
+
+// PersonCastCredit is a role in a person's filmography (person/{id}/movie_credits).
+type PersonCastCredit struct {
+	ID            int    `json:"id"` // TMDB movie id
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	ReleaseDate   string `json:"release_date"`
+	Character     string `json:"character"`
+	Order         int    `json:"order"`
+	CreditID      string `json:"credit_id"`
+	Video         bool   `json:"video"`
+	GenreIDs      []int  `json:"genre_ids"`
+}
+
+// PersonCrewCredit is a job in a person's filmography.
+type PersonCrewCredit struct {
+	ID            int    `json:"id"` // TMDB movie id
+	Title         string `json:"title"`
+	OriginalTitle string `json:"original_title"`
+	ReleaseDate   string `json:"release_date"`
+	Job           string `json:"job"`
+	Department    string `json:"department"`
+	CreditID      string `json:"credit_id"`
+	Video         bool   `json:"video"`
+	GenreIDs      []int  `json:"genre_ids"`
+}
+
+// PersonMovieCredits is the movie filmography of a person.
+type PersonMovieCredits struct {
+	ID   int                `json:"id"` // TMDB person id
+	Cast []PersonCastCredit `json:"cast"`
+	Crew []PersonCrewCredit `json:"crew"`
+}
+
+// Person fetches the details of a person.
+func (c *Client) Person(ctx context.Context, id int) (Person, error) {
+	var p Person
+	if err := c.get(ctx, fmt.Sprintf("person/%d", id), nil, &p); err != nil {
+		return p, fmt.Errorf("failed to fetch person: %w", err)
+	}
+	return p, nil
+}
+
+// PersonMovieCredits fetches the movie filmography of a person.
+func (c *Client) PersonMovieCredits(ctx context.Context, id int) (PersonMovieCredits, error) {
+	var credits PersonMovieCredits
+	if err := c.get(ctx, fmt.Sprintf("person/%d/movie_credits", id), nil, &credits); err != nil {
+		return credits, fmt.Errorf("failed to fetch movie credits: %w", err)
+	}
+	return credits, nil
+}